@@ -20,7 +20,7 @@ Usage:
 Flags:
   -config string     Path to apigen.toml (default "apigen.toml")
   -model string      Model struct name (required, e.g. "User")
-  -table string      Override table name (default: snake_case plural of model)
+  -table string      Override table name (default: TableName() or the [Naming] strategy)
   -select string     Comma-separated field names to include (default: all)
   -omit string       Comma-separated field names to exclude
   -filter string     Custom filters as "Column:Op:GoType" (repeatable, comma-separated)
@@ -63,7 +63,17 @@ func main() {
 		log.Fatalf("error loading config: %v\n", err)
 	}
 
-	meta := parser.Parse(cfg.Models.Pkgs)
+	meta, diagnostics, err := parser.Parse(cfg.Models.Pkgs, cfg.Naming.Strategy())
+	if err != nil {
+		log.Fatalf("error parsing models: %v\n", err)
+	}
+
+	for _, d := range diagnostics {
+		fmt.Fprintln(os.Stderr, d)
+	}
+	if parser.HasErrors(diagnostics) {
+		log.Fatalln("models have errors, fix them to generate code")
+	}
 
 	// Find the model's package
 	modelPkg := ""
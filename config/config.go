@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 
 	"github.com/pelletier/go-toml/v2"
+	"gorm.io/gorm/schema"
 )
 
 // Config struct represents the configuration parameters
@@ -23,6 +24,23 @@ type Config struct {
 	// Set to false to skip refetch — callers can call .Get(id) explicitly when they need associations.
 	RefetchAfterWrite *bool `toml:"RefetchAfterWrite"`
 
+	// Context selects how generated service methods accept a context.Context. Defaults to "none".
+	Context ContextAPI `toml:"Context"`
+
+	// Strict when true, makes the generated Order, Select, Group and ILIKE options accept only
+	// the columns of the queried model, failing the query with a *repo.IdentifierError otherwise.
+	// Use it when these options are fed from request parameters.
+	Strict bool `toml:"Strict"`
+
+	// Audit lists the models whose writes are recorded in the audit table of repo.AuditEntry
+	// e.g ['Patient', 'Visit']. A History method reads the audit entries of a record.
+	Audit []string `toml:"Audit"`
+
+	// Tenant is the column scoping the models having it to the tenant of the context of
+	// their queries e.g "tenant_id", see repo.UseTenancy. The generated NewService then
+	// returns an error too. Empty to disable multi-tenancy.
+	Tenant string `toml:"Tenant"`
+
 	Models struct {
 		Pkgs     []string `toml:"Pkgs"`     // absolute package names where models are located
 		Skip     []string `toml:"Skip"`     // Slice of models(Structs) to skip
@@ -35,6 +53,41 @@ type Config struct {
 	Overrides    Overrides `toml:"overrides"`
 	PreloadDepth uint      `toml:"PreloadDepth"` // Preload depth for nested relations
 	Queries      Queries   `toml:"Queries"`
+	Naming       Naming    `toml:"Naming"`
+
+	// Upsert maps model names to the conflict columns of their Upsert methods e.g User = ['email'].
+	// Models default to their first unique index, then their primary key.
+	Upsert map[string][]string `toml:"Upsert"`
+
+	// Keyset maps model names to the columns ordering the pages of GetPage e.g User = ['-created_at', 'id'].
+	// Columns prefixed with "-" are sorted in descending order. Models default to their primary key.
+	// Nullable columns are rejected, their NULL rows would be skipped.
+	Keyset map[string][]string `toml:"Keyset"`
+}
+
+// ContextAPI selects how generated service methods accept a context.Context.
+type ContextAPI string
+
+const (
+	ContextAPINone ContextAPI = "none" // Methods take no context, pass one with the WithContext option
+	ContextAPIOnly ContextAPI = "only" // Methods take a context.Context first e.g Get(ctx, id)
+	ContextAPIBoth ContextAPI = "both" // Both method sets. Context-first methods are suffixed e.g GetContext(ctx, id)
+)
+
+// Naming configures how table and column names are derived from models.
+// It must match the gorm.Config NamingStrategy of the application.
+type Naming struct {
+	TablePrefix   string `toml:"TablePrefix"`   // Prefix of all tables e.g "app_" for app_users
+	SingularTable bool   `toml:"SingularTable"` // Use singular table names e.g user instead of users
+}
+
+// Strategy returns the GORM naming strategy configured by n.
+// Table names are pluralized like GORM does e.g Category -> categories.
+func (n Naming) Strategy() schema.NamingStrategy {
+	return schema.NamingStrategy{
+		TablePrefix:   n.TablePrefix,
+		SingularTable: n.SingularTable,
+	}
 }
 
 type QuerySettings struct {
@@ -46,6 +99,7 @@ type QuerySet struct {
 	Get                  QuerySettings `toml:"Get"`
 	GetAll               QuerySettings `toml:"GetAll"`
 	GetPaginated         QuerySettings `toml:"GetPaginated"`
+	GetPage              QuerySettings `toml:"GetPage"`
 	FindOne              QuerySettings `toml:"FindOne"`
 	FindMany             QuerySettings `toml:"FindMany"`
 	Create               QuerySettings `toml:"Create"`
@@ -53,6 +107,10 @@ type QuerySet struct {
 	Update               QuerySettings `toml:"Update"`
 	PartialUpdate        QuerySettings `toml:"PartialUpdate"`
 	PartialUpdateWithMap QuerySettings `toml:"PartialUpdateWithMap"`
+	Upsert               QuerySettings `toml:"Upsert"`
+	UpsertMany           QuerySettings `toml:"UpsertMany"`
+	FirstOrCreate        QuerySettings `toml:"FirstOrCreate"`
+	FirstOrInit          QuerySettings `toml:"FirstOrInit"`
 }
 
 type Queries struct {
@@ -113,6 +171,8 @@ func (q QuerySet) lookup(operation string) QuerySettings {
 		return q.GetAll
 	case "GetPaginated":
 		return q.GetPaginated
+	case "GetPage":
+		return q.GetPage
 	case "FindOne":
 		return q.FindOne
 	case "FindMany":
@@ -127,6 +187,14 @@ func (q QuerySet) lookup(operation string) QuerySettings {
 		return q.PartialUpdate
 	case "PartialUpdateWithMap":
 		return q.PartialUpdateWithMap
+	case "Upsert":
+		return q.Upsert
+	case "UpsertMany":
+		return q.UpsertMany
+	case "FirstOrCreate":
+		return q.FirstOrCreate
+	case "FirstOrInit":
+		return q.FirstOrInit
 	default:
 		return QuerySettings{}
 	}
@@ -160,6 +228,26 @@ func validateConfig(cfg *Config) error {
 		return fmt.Errorf("models.Pkgs is empty in apigen.toml")
 	}
 
+	switch cfg.Context {
+	case "":
+		cfg.Context = ContextAPINone
+	case ContextAPINone, ContextAPIOnly, ContextAPIBoth:
+	default:
+		return fmt.Errorf("error: Context must be %q, %q or %q in apigen.toml, got %q",
+			ContextAPINone, ContextAPIOnly, ContextAPIBoth, cfg.Context)
+	}
+
+	for model, columns := range cfg.Upsert {
+		if len(columns) == 0 {
+			return fmt.Errorf("error: Upsert.%s has no conflict columns in apigen.toml", model)
+		}
+	}
+	for model, columns := range cfg.Keyset {
+		if len(columns) == 0 {
+			return fmt.Errorf("error: Keyset.%s has no columns in apigen.toml", model)
+		}
+	}
+
 	for _, pkg := range cfg.Models.Pkgs {
 		if pkg == "" {
 			return fmt.Errorf("error: Models.Pkgs has an empty pkg in apigen.toml")
@@ -1,6 +1,11 @@
 package config
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
 
 func boolPtr(value bool) *bool {
 	return &value
@@ -53,3 +58,85 @@ func TestQueryConfigAppliesDefaultAndModelOverrides(t *testing.T) {
 		t.Fatalf("expected default GetAll preload override for other models")
 	}
 }
+
+func TestNamingStrategy(t *testing.T) {
+	namer := Naming{TablePrefix: "app_", SingularTable: true}.Strategy()
+	if table := namer.TableName("Category"); table != "app_category" {
+		t.Fatalf("expected prefixed singular table, got %q", table)
+	}
+
+	namer = Naming{}.Strategy()
+	if table := namer.TableName("Category"); table != "categories" {
+		t.Fatalf("expected GORM pluralization by default, got %q", table)
+	}
+}
+
+func TestValidateContextAPI(t *testing.T) {
+	cfg := &Config{}
+	cfg.Models.Pkgs = []string{"github.com/example/project/models"}
+	if err := validateConfig(cfg); err != nil {
+		t.Fatalf("validateConfig returned error: %v", err)
+	}
+	if cfg.Context != ContextAPINone {
+		t.Fatalf("expected Context to default to %q, got %q", ContextAPINone, cfg.Context)
+	}
+
+	cfg.Context = ContextAPIBoth
+	if err := validateConfig(cfg); err != nil {
+		t.Fatalf("validateConfig returned error: %v", err)
+	}
+
+	cfg.Context = "always"
+	if err := validateConfig(cfg); err == nil {
+		t.Fatalf("expected an error for an unknown Context")
+	}
+}
+
+func TestLoadUpsertConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apigen.toml")
+	content := `
+[Models]
+Pkgs = ['github.com/example/project/models']
+
+[Upsert]
+User = ['email']
+
+[Queries.Models.User.Upsert]
+RefetchAfterWrite = false
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig returned error: %v", err)
+	}
+	if !slices.Equal(cfg.Upsert["User"], []string{"email"}) {
+		t.Fatalf("expected User conflict columns [email], got %v", cfg.Upsert["User"])
+	}
+	if cfg.QueryConfig("User", "Upsert").RefetchAfterWrite {
+		t.Fatalf("expected model-specific Upsert refetch override to win")
+	}
+	if !cfg.QueryConfig("User", "FirstOrCreate").RefetchAfterWrite {
+		t.Fatalf("expected FirstOrCreate to use the global refetch default")
+	}
+
+	cfg.Upsert["Role"] = nil
+	if err := validateConfig(cfg); err == nil {
+		t.Fatalf("expected an error for a model without conflict columns")
+	}
+}
+
+func TestValidateKeyset(t *testing.T) {
+	cfg := &Config{Keyset: map[string][]string{"User": {"-created_at", "id"}}}
+	cfg.Models.Pkgs = []string{"github.com/example/project/models"}
+	if err := validateConfig(cfg); err != nil {
+		t.Fatalf("validateConfig returned error: %v", err)
+	}
+
+	cfg.Keyset["Role"] = []string{}
+	if err := validateConfig(cfg); err == nil {
+		t.Fatalf("expected an error for a model without keyset columns")
+	}
+}
@@ -59,6 +59,11 @@ func generateCode(any) error {
 		return fmt.Errorf("error loading config file: %v", err)
 	}
 
+	metadata, enums, err := parseModels(cfg)
+	if err != nil {
+		return err
+	}
+
 	// If tsTypesPath is not empty generate the types
 	if tsTypesPath != "" {
 		f, err := os.OpenFile(tsTypesPath, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
@@ -66,19 +71,45 @@ func generateCode(any) error {
 			return fmt.Errorf("error opening typescript types file: %v", err)
 		}
 
-		meta := parser.Parse(cfg.Models.Pkgs)
-		mapMeta := parser.Map(meta)
-		typescript.GenerateTypescriptInterfaces(f, mapMeta, cfg.Overrides)
+		overrides, diagnostics := typescript.WithEnums(cfg.Overrides, enums)
+		for _, d := range diagnostics {
+			fmt.Fprintln(os.Stderr, d)
+		}
+
+		mapMeta := parser.Map(metadata)
+		typescript.GenerateTypescriptInterfaces(f, mapMeta, overrides)
 	}
 
-	metadata := parser.Parse(cfg.Models.Pkgs)
 	err = parser.GenerateGORMServices(cfg, metadata)
 	if err != nil {
 		return fmt.Errorf("error generating code: %v", err)
 	}
+
+	err = parser.GenerateEnums(cfg, enums)
+	if err != nil {
+		return fmt.Errorf("error generating enums: %v", err)
+	}
 	return nil
 }
 
+// parseModels parses the models and enums of the model packages and prints the diagnostics
+// to stderr. It fails if any of them is an error.
+func parseModels(cfg *config.Config) ([]parser.StructMeta, []parser.Enum, error) {
+	metadata, enums, diagnostics, err := parser.ParseWithEnums(cfg.Models.Pkgs, cfg.Naming.Strategy())
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing models: %v", err)
+	}
+
+	for _, d := range diagnostics {
+		fmt.Fprintln(os.Stderr, d)
+	}
+
+	if parser.HasErrors(diagnostics) {
+		return nil, nil, fmt.Errorf("models have errors, fix them to generate code")
+	}
+	return metadata, enums, nil
+}
+
 func initConfigFile(any) error {
 	// If config file already exists, print message and return
 	if _, err := os.Stat(configName); err == nil {
@@ -1,5 +1,5 @@
-// Package models defines the data models used in the application, including User, Role, Tag, Issue, Question, and Comment.
 // Code generated by "apigen"; DO NOT EDIT.
+
 package models
 
 import (
@@ -7,6 +7,7 @@ import (
 	"fmt"
 )
 
+// IsValid reports whether e is one of the Sex constants.
 func (e Sex) IsValid() bool {
 	for _, val := range e.ValidValues() {
 		if val == string(e) {
@@ -16,6 +17,7 @@ func (e Sex) IsValid() bool {
 	return false
 }
 
+// ValidValues returns the values of the Sex constants.
 func (e Sex) ValidValues() []string {
 	return []string{
 		"Male",
@@ -23,26 +25,35 @@ func (e Sex) ValidValues() []string {
 	}
 }
 
+// Scan implements sql.Scanner. NULL scans to the zero value.
 func (e *Sex) Scan(src any) error {
-	source, ok := src.(string)
-	if !ok {
-		return fmt.Errorf("invalid value for %s: %s", "Sex", source)
+	switch source := src.(type) {
+	case nil:
+		*e = ""
+	case string:
+		*e = Sex(source)
+	case []byte:
+		*e = Sex(source)
+	default:
+		return fmt.Errorf("invalid value for %s: %v", "Sex", src)
 	}
-	*e = Sex(source)
 	return nil
 }
 
+// Value implements driver.Valuer. Values other than the Sex constants are rejected.
 func (e Sex) Value() (driver.Value, error) {
 	if !e.IsValid() {
-		return nil, fmt.Errorf("invalid value for %s", "Sex")
+		return nil, fmt.Errorf("invalid value for %s: %v", "Sex", string(e))
 	}
 	return string(e), nil
 }
 
+// DatabaseType returns the Postgres enum type of Sex.
 func (e Sex) DatabaseType() string {
 	return "sex"
 }
 
+// GormDataType returns the column type of Sex fields used by migrations.
 func (e Sex) GormDataType() string {
 	return "sex"
 }
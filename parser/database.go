@@ -13,6 +13,7 @@ import (
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
 )
 
 // PostgresConnection establishes a connection to a Postgres database using GORM.
@@ -31,6 +32,8 @@ func PostgresConnection(dsn string, timezone string, logLevel logger.LogLevel, l
 			}
 			return time.Now().In(loc)
 		},
+		// NamingStrategy matches the [Naming] section of apigen.toml used to generate the code.
+		NamingStrategy:                   schema.NamingStrategy{TablePrefix: %q, SingularTable: %t},
 		PrepareStmt:                      true,
 		IgnoreRelationshipsWhenMigrating: false,
 		Logger: logger.New(log.New(logOut, "\r\n", log.LstdFlags),
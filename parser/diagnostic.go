@@ -0,0 +1,134 @@
+package parser
+
+import (
+	"fmt"
+	"go/token"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Severity is the severity of a Diagnostic.
+type Severity string
+
+const (
+	// SeverityWarning is reported for models that can be generated, but not as written
+	// e.g a field with an unsupported type is ignored.
+	SeverityWarning Severity = "warning"
+
+	// SeverityError is reported for models that can't be generated correctly
+	// e.g a package that does not compile or a malformed gorm tag.
+	SeverityError Severity = "error"
+)
+
+// Diagnostic describes a problem found in a model while parsing.
+type Diagnostic struct {
+	Pos      token.Position // Position of the offending struct or field
+	Severity Severity       // Whether generation should fail
+	Struct   string         // Struct name e.g "User". Empty for package level diagnostics
+	Field    string         // Field name. Empty for struct level diagnostics
+	Message  string         // Reason e.g "unknown gorm setting"
+}
+
+// String formats the diagnostic as "file:line:col: severity: Struct.Field: message".
+func (d Diagnostic) String() string {
+	name := d.Struct
+	if d.Field != "" {
+		name += "." + d.Field
+	}
+
+	if name == "" {
+		return fmt.Sprintf("%s: %s: %s", d.Pos, d.Severity, d.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s: %s", d.Pos, d.Severity, name, d.Message)
+}
+
+// HasErrors reports whether any of diagnostics is an error.
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// packageDiagnostics returns the load and type errors of pkg as error diagnostics.
+// Errors reported by the go command without a position list one
+// "file:line:col: message" per line, they are split into separate diagnostics.
+func packageDiagnostics(pkg *packages.Package) []Diagnostic {
+	var diagnostics []Diagnostic
+	report := func(pos, msg string) {
+		diagnostics = append(diagnostics, Diagnostic{
+			Pos:      parsePosition(pos),
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("package %s: %s", pkg.PkgPath, msg),
+		})
+	}
+
+	for _, err := range pkg.Errors {
+		if err.Pos != "" && err.Pos != "-" {
+			report(err.Pos, err.Msg)
+			continue
+		}
+
+		split := false
+		for line := range strings.SplitSeq(err.Msg, "\n") {
+			if pos, msg, ok := cutPosition(line); ok {
+				report(pos, msg)
+				split = true
+			}
+		}
+		if !split {
+			report(err.Pos, err.Msg)
+		}
+	}
+	return diagnostics
+}
+
+// cutPosition splits a "file:line:col: message" line into its position and message.
+func cutPosition(line string) (pos, msg string, ok bool) {
+	for i := 0; i+2 <= len(line); i++ {
+		if line[i] != ':' || line[i+1] != ' ' {
+			continue
+		}
+		if p := parsePosition(line[:i]); p.Line > 0 {
+			return line[:i], line[i+2:], true
+		}
+	}
+	return "", "", false
+}
+
+// parsePosition parses a position formatted as "file:line:col", "file:line" or "file".
+// It is the format of packages.Error.Pos.
+func parsePosition(pos string) token.Position {
+	var p token.Position
+
+	rest, last, ok := cutLastNumber(pos)
+	if !ok {
+		p.Filename = pos
+		return p
+	}
+
+	if file, line, ok := cutLastNumber(rest); ok {
+		p.Filename, p.Line, p.Column = file, line, last
+	} else {
+		p.Filename, p.Line = rest, last
+	}
+	return p
+}
+
+// cutLastNumber splits s around its last colon if it is followed by a number.
+func cutLastNumber(s string) (string, int, bool) {
+	i := strings.LastIndexByte(s, ':')
+	if i < 0 {
+		return s, 0, false
+	}
+
+	n, err := strconv.Atoi(s[i+1:])
+	if err != nil {
+		return s, 0, false
+	}
+	return s[:i], n, true
+}
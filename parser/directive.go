@@ -0,0 +1,155 @@
+package parser
+
+import (
+	"go/ast"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+// Directives are written in doc comments of models and their fields e.g
+//
+//	// User is a registered user.
+//	//
+//	// apigen:readonly
+//	// apigen:preload=Role,Tags
+//	type User struct {
+//		Password string // apigen:hidden
+//	}
+const (
+	directiveSkip      = "skip"      // Don't generate a service for the model
+	directiveReadOnly  = "readonly"  // Only generate read methods e.g for SQL views
+	directiveTable     = "table"     // Table of the model e.g apigen:table=app_users
+	directivePreload   = "preload"   // Comma-separated relations to preload e.g apigen:preload=Role,Role.Permissions
+	directiveNoPreload = "nopreload" // Don't preload any relation
+	directiveHidden    = "hidden"    // Field is never read by generated code nor exposed in typescript
+	directiveVersion   = "version"   // Field is the version column of optimistic locking
+)
+
+// directivePattern matches a directive and its optional value in a comment line.
+var directivePattern = regexp.MustCompile(`\bapigen:([a-z]+)(?:=(\S*))?`)
+
+// directive is a single apigen:name[=value] directive.
+type directive struct {
+	Pos   token.Pos
+	Name  string
+	Value string
+	Set   bool // Whether a value was given with =
+}
+
+// parseDirectives returns the directives in comment groups, in order.
+func parseDirectives(groups ...*ast.CommentGroup) []directive {
+	var directives []directive
+	for _, group := range groups {
+		if group == nil {
+			continue
+		}
+		for _, comment := range group.List {
+			for _, m := range directivePattern.FindAllStringSubmatchIndex(comment.Text, -1) {
+				d := directive{
+					Pos:  comment.Pos() + token.Pos(m[0]),
+					Name: comment.Text[m[2]:m[3]],
+				}
+				if m[4] >= 0 {
+					d.Value = comment.Text[m[4]:m[5]]
+					d.Set = true
+				}
+				directives = append(directives, d)
+			}
+		}
+	}
+	return directives
+}
+
+// applyStructDirectives sets the options of meta given by its doc comment directives.
+// It must be called once the fields of meta are collected.
+func (p *modelParser) applyStructDirectives(meta *StructMeta, doc *ast.CommentGroup) {
+	for _, d := range parseDirectives(doc) {
+		switch d.Name {
+		case directiveSkip:
+			meta.Skip = true
+		case directiveReadOnly:
+			meta.ReadOnly = true
+		case directiveNoPreload:
+			meta.NoPreload = true
+		case directiveTable:
+			if d.Value == "" {
+				p.report(d.Pos, SeverityError, meta.Name, "", "apigen:table requires a table name e.g apigen:table=users")
+				continue
+			}
+			meta.Table = d.Value
+			meta.TableOverride = true
+		case directivePreload:
+			if d.Value == "" {
+				p.report(d.Pos, SeverityError, meta.Name, "", "apigen:preload requires relations e.g apigen:preload=Role,Tags")
+				continue
+			}
+			for preload := range strings.SplitSeq(d.Value, ",") {
+				name, _, _ := strings.Cut(preload, ".")
+				if !hasField(meta, name) {
+					p.report(d.Pos, SeverityError, meta.Name, "", "apigen:preload references unknown field %q", name)
+					continue
+				}
+				meta.Preloads = append(meta.Preloads, preload)
+			}
+		case directiveHidden, directiveVersion:
+			p.report(d.Pos, SeverityWarning, meta.Name, "", "apigen:%s only applies to fields", d.Name)
+		default:
+			p.report(d.Pos, SeverityWarning, meta.Name, "", "unknown directive apigen:%s", d.Name)
+		}
+
+		if d.Set && d.Name != directiveTable && d.Name != directivePreload {
+			p.report(d.Pos, SeverityWarning, meta.Name, "", "apigen:%s does not take a value", d.Name)
+		}
+	}
+
+	if meta.NoPreload && len(meta.Preloads) > 0 {
+		p.report(doc.Pos(), SeverityError, meta.Name, "", "apigen:preload and apigen:nopreload are mutually exclusive")
+	}
+}
+
+// applyFieldDirectives sets the options of field given by the directives in its comments.
+func (p *modelParser) applyFieldDirectives(field *Field, structName string, pos token.Pos) {
+	for _, d := range parseDirectives(p.fieldComments(pos)...) {
+		switch d.Name {
+		case directiveHidden:
+			field.Hidden = true
+		case directiveVersion:
+			if !isVersionType(*field) {
+				p.report(d.Pos, SeverityError, structName, field.Name, "apigen:version requires an integer field, got %s", field.Type)
+				continue
+			}
+			field.Version = true
+		default:
+			p.report(d.Pos, SeverityWarning, structName, field.Name, "apigen:%s does not apply to fields", d.Name)
+			continue
+		}
+
+		if d.Set {
+			p.report(d.Pos, SeverityWarning, structName, field.Name, "apigen:%s does not take a value", d.Name)
+		}
+	}
+}
+
+// fieldComments returns the doc and line comments of the struct field declared at pos.
+// Fields are looked up in all struct types of the package, including those embedded in models.
+// Embedded fields have no directives.
+func (p *modelParser) fieldComments(pos token.Pos) []*ast.CommentGroup {
+	if p.comments == nil {
+		p.comments = make(map[token.Pos][]*ast.CommentGroup)
+		for _, file := range p.pkg.Syntax {
+			ast.Inspect(file, func(n ast.Node) bool {
+				field, ok := n.(*ast.Field)
+				if !ok || (field.Doc == nil && field.Comment == nil) {
+					return true
+				}
+
+				for _, name := range field.Names {
+					p.comments[name.Pos()] = []*ast.CommentGroup{field.Doc, field.Comment}
+				}
+				return true
+			})
+		}
+	}
+	return p.comments[pos]
+}
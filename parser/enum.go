@@ -0,0 +1,432 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/format"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/abiiranathan/apigen/config"
+	"github.com/iancoleman/strcase"
+	"golang.org/x/tools/go/packages"
+)
+
+// Enum is a named string or integer type of a model package with constants of that type e.g
+//
+//	type Sex string
+//
+//	const (
+//		Male   Sex = "Male"
+//		Female Sex = "Female"
+//	)
+type Enum struct {
+	Name    string      // Type name e.g "Sex"
+	Package string      // Import path of the package declaring the type
+	PkgName string      // Name of the package declaring the type e.g "models"
+	Dir     string      // Directory of the package declaring the type
+	Kind    Kind        // KindString, KindInt or KindUint
+	Values  []EnumValue // Distinct values in declaration order
+
+	Pos token.Position // Position of the type declaration
+}
+
+// EnumValue is a constant of an Enum.
+type EnumValue struct {
+	Name  string // Constant name e.g "Male"
+	Value string // Go literal of the value e.g `"Male"` or `1`
+}
+
+// DatabaseType returns the name of the Postgres enum type of e e.g "sex".
+func (e Enum) DatabaseType() string {
+	return strcase.ToSnake(e.Name)
+}
+
+// CreateTypeSQL returns the statement creating the Postgres enum type of string enum e
+// e.g CREATE TYPE sex AS ENUM ('Male', 'Female'). It returns "" for integer enums.
+func (e Enum) CreateTypeSQL() string {
+	if e.Kind != KindString {
+		return ""
+	}
+
+	labels := make([]string, len(e.Values))
+	for i, v := range e.Values {
+		label, _ := strconv.Unquote(v.Value)
+		labels[i] = "'" + strings.ReplaceAll(label, "'", "''") + "'"
+	}
+	return fmt.Sprintf("CREATE TYPE %s AS ENUM (%s)", e.DatabaseType(), strings.Join(labels, ", "))
+}
+
+// TypescriptUnion returns the union of the JSON values of e e.g `"Male" | "Female"`.
+func (e Enum) TypescriptUnion() string {
+	values := make([]string, len(e.Values))
+	for i, v := range e.Values {
+		values[i] = v.Value
+		if e.Kind == KindString {
+			label, _ := strconv.Unquote(v.Value)
+			quoted, _ := json.Marshal(label)
+			values[i] = string(quoted)
+		}
+	}
+	return strings.Join(values, " | ")
+}
+
+// enumMethods are the methods generated for enums. Types declaring any of them
+// outside the generated file are not enums, they implement their own.
+var enumMethods = []string{"IsValid", "ValidValues", "Scan", "Value", "DatabaseType", "GormDataType"}
+
+// enumFileName returns the name of the file with the generated enum methods of package pkgName.
+func enumFileName(pkgName string) string {
+	return pkgName + "_enum.go"
+}
+
+// ParseEnums returns the enums declared in packages modelPkgs: exported named string or
+// integer types with at least one constant of that type. Types marked with apigen:skip
+// or implementing the generated methods themselves are left out.
+// Packages that fail to type-check are skipped, Parse reports their errors.
+func ParseEnums(modelPkgs []string) ([]Enum, error) {
+	pkgs, err := loadPackages(modelPkgs)
+	if err != nil {
+		return nil, err
+	}
+	return packagesEnums(pkgs), nil
+}
+
+// packagesEnums returns the enums of the loaded packages pkgs, see ParseEnums.
+func packagesEnums(pkgs []*packages.Package) []Enum {
+	var enums []Enum
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			continue
+		}
+		enums = append(enums, packageEnums(pkg)...)
+	}
+	return enums
+}
+
+// packageEnums returns the enums declared in pkg, in declaration order.
+func packageEnums(pkg *packages.Package) []Enum {
+	var (
+		enums   []Enum
+		indexes = make(map[*types.TypeName]int)
+	)
+
+	dir := ""
+	if len(pkg.GoFiles) > 0 {
+		dir = filepath.Dir(pkg.GoFiles[0])
+	}
+
+	// Types come first so that constants declared before their type are found.
+	for _, file := range pkg.Syntax {
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+
+			for _, spec := range genDecl.Specs {
+				t := spec.(*ast.TypeSpec)
+				doc := t.Doc
+				if doc == nil && len(genDecl.Specs) == 1 {
+					doc = genDecl.Doc
+				}
+
+				obj, ok := pkg.TypesInfo.Defs[t.Name].(*types.TypeName)
+				if !ok || !isEnumType(pkg, obj) || hasSkipDirective(doc) {
+					continue
+				}
+
+				indexes[obj] = len(enums)
+				enums = append(enums, Enum{
+					Name:    obj.Name(),
+					Package: pkg.PkgPath,
+					PkgName: pkg.Name,
+					Dir:     dir,
+					Kind:    kindOf(obj.Type()),
+					Pos:     pkg.Fset.Position(t.Name.Pos()),
+				})
+			}
+		}
+	}
+
+	for _, file := range pkg.Syntax {
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.CONST {
+				continue
+			}
+
+			for _, spec := range genDecl.Specs {
+				for _, name := range spec.(*ast.ValueSpec).Names {
+					c, ok := pkg.TypesInfo.Defs[name].(*types.Const)
+					if !ok || name.Name == "_" {
+						continue
+					}
+
+					named, ok := c.Type().(*types.Named)
+					if !ok {
+						continue
+					}
+
+					i, ok := indexes[named.Obj()]
+					if !ok {
+						continue
+					}
+
+					value, ok := enumLiteral(c.Val())
+					if ok && !slices.ContainsFunc(enums[i].Values, func(v EnumValue) bool { return v.Value == value }) {
+						enums[i].Values = append(enums[i].Values, EnumValue{Name: c.Name(), Value: value})
+					}
+				}
+			}
+		}
+	}
+
+	return slices.DeleteFunc(enums, func(e Enum) bool { return len(e.Values) == 0 })
+}
+
+// isEnumType reports whether obj is an exported named string or integer type
+// without methods of its own named like the generated enum methods.
+func isEnumType(pkg *packages.Package, obj *types.TypeName) bool {
+	if obj.IsAlias() || !obj.Exported() {
+		return false
+	}
+
+	named, ok := obj.Type().(*types.Named)
+	if !ok || named.TypeParams().Len() > 0 {
+		return false
+	}
+
+	switch kindOf(named) {
+	case KindString, KindInt, KindUint:
+	default:
+		return false
+	}
+
+	generated := enumFileName(pkg.Name)
+	for method := range named.Methods() {
+		pos := pkg.Fset.Position(method.Pos())
+		if slices.Contains(enumMethods, method.Name()) && filepath.Base(pos.Filename) != generated {
+			return false
+		}
+	}
+	return true
+}
+
+// hasSkipDirective reports whether doc has the apigen:skip directive.
+func hasSkipDirective(doc *ast.CommentGroup) bool {
+	return slices.ContainsFunc(parseDirectives(doc), func(d directive) bool {
+		return d.Name == directiveSkip
+	})
+}
+
+// enumLiteral returns the Go literal of constant value v. Integers must fit in an int64,
+// the type the generated methods use for integer values.
+func enumLiteral(v constant.Value) (string, bool) {
+	switch v.Kind() {
+	case constant.String:
+		return strconv.Quote(constant.StringVal(v)), true
+	case constant.Int:
+		if i, exact := constant.Int64Val(v); exact {
+			return strconv.FormatInt(i, 10), true
+		}
+	}
+	return "", false
+}
+
+// GenerateEnums writes the enum methods to a file in the directory of each package
+// declaring enums e.g models/models_enum.go, and the statements creating their
+// Postgres types to enums.go in the configured output package.
+func GenerateEnums(cfg *config.Config, enums []Enum) error {
+	files, err := generateEnumFiles(enums)
+	if err != nil {
+		return err
+	}
+
+	for path, content := range files {
+		if err := writeFile(path, content); err != nil {
+			return fmt.Errorf("error writing to file %q: %w", path, err)
+		}
+	}
+
+	content, err := generateEnumTypes(cfg, enums)
+	if err != nil {
+		return err
+	}
+
+	targetDir := filepath.Join(cfg.Output.OutDir, cfg.Output.ServiceName)
+	if err := createDirectory(targetDir); err != nil {
+		return fmt.Errorf("error creating directory %s: %w", cfg.Output.ServiceName, err)
+	}
+
+	targetPath := filepath.Join(targetDir, "enums.go")
+	if err := writeFile(targetPath, content); err != nil {
+		return fmt.Errorf("error writing to file %q: %w", targetPath, err)
+	}
+	return nil
+}
+
+// generateEnumFiles returns the formatted enum methods of each package, keyed by file path.
+func generateEnumFiles(enums []Enum) (map[string][]byte, error) {
+	byPkg := make(map[string][]Enum)
+	var pkgs []string
+	for _, e := range enums {
+		if _, ok := byPkg[e.Package]; !ok {
+			pkgs = append(pkgs, e.Package)
+		}
+		byPkg[e.Package] = append(byPkg[e.Package], e)
+	}
+
+	tmpl, err := template.New("enums").Parse(enumTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing enum template: %w", err)
+	}
+
+	files := make(map[string][]byte, len(pkgs))
+	for _, pkg := range pkgs {
+		pkgEnums := byPkg[pkg]
+
+		buf := new(bytes.Buffer)
+		if err := tmpl.Execute(buf, pkgEnums); err != nil {
+			return nil, fmt.Errorf("error executing enum template: %w", err)
+		}
+
+		content, err := format.Source(buf.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("error formatting enums of %s: %w", pkg, err)
+		}
+		files[filepath.Join(pkgEnums[0].Dir, enumFileName(pkgEnums[0].PkgName))] = content
+	}
+	return files, nil
+}
+
+// generateEnumTypes returns the formatted enums.go file of the output package.
+func generateEnumTypes(cfg *config.Config, enums []Enum) ([]byte, error) {
+	tmpl, err := template.New("enumTypes").Parse(enumTypesTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing enum types template: %w", err)
+	}
+
+	var statements []string
+	for _, e := range enums {
+		if stmt := e.CreateTypeSQL(); stmt != "" && !slices.Contains(statements, stmt) {
+			statements = append(statements, stmt)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	data := map[string]any{"PkgName": cfg.Output.ServiceName, "Statements": statements}
+	if err := tmpl.Execute(buf, data); err != nil {
+		return nil, fmt.Errorf("error executing enum types template: %w", err)
+	}
+
+	content, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("error formatting enum types: %w", err)
+	}
+	return content, nil
+}
+
+var enumTemplate = `// Code generated by "apigen"; DO NOT EDIT.
+
+package {{ (index . 0).PkgName }}
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+{{ range . }}
+{{- $elem := "int64" }}{{ if eq .Kind "string" }}{{ $elem = "string" }}{{ end }}
+// IsValid reports whether e is one of the {{.Name}} constants.
+func (e {{.Name}}) IsValid() bool {
+	for _, val := range e.ValidValues() {
+		if val == {{$elem}}(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidValues returns the values of the {{.Name}} constants.
+func (e {{.Name}}) ValidValues() []{{$elem}} {
+	return []{{$elem}}{
+		{{- range .Values }}
+		{{.Value}},
+		{{- end }}
+	}
+}
+
+// Scan implements sql.Scanner. NULL scans to the zero value.
+func (e *{{.Name}}) Scan(src any) error {
+	switch source := src.(type) {
+	case nil:
+		*e = {{ if eq .Kind "string" }}""{{ else }}0{{ end }}
+	{{- if eq .Kind "string" }}
+	case string:
+		*e = {{.Name}}(source)
+	case []byte:
+		*e = {{.Name}}(source)
+	{{- else }}
+	case int64:
+		*e = {{.Name}}(source)
+	{{- end }}
+	default:
+		return fmt.Errorf("invalid value for %s: %v", "{{.Name}}", src)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer. Values other than the {{.Name}} constants are rejected.
+func (e {{.Name}}) Value() (driver.Value, error) {
+	if !e.IsValid() {
+		return nil, fmt.Errorf("invalid value for %s: %v", "{{.Name}}", {{$elem}}(e))
+	}
+	return {{$elem}}(e), nil
+}
+{{ if eq .Kind "string" }}
+// DatabaseType returns the Postgres enum type of {{.Name}}.
+func (e {{.Name}}) DatabaseType() string {
+	return "{{.DatabaseType}}"
+}
+
+// GormDataType returns the column type of {{.Name}} fields used by migrations.
+func (e {{.Name}}) GormDataType() string {
+	return "{{.DatabaseType}}"
+}
+{{ end }}
+{{- end }}
+`
+
+var enumTypesTemplate = `// Code generated by "apigen"; DO NOT EDIT.
+
+package {{.PkgName}}
+
+import "gorm.io/gorm"
+
+// EnumTypes are the statements creating the Postgres enum types of the models.
+var EnumTypes = []string{
+	{{- range .Statements }}
+	{{ printf "%q" . }},
+	{{- end }}
+}
+
+// CreateEnumTypes creates the Postgres enum types of the models that don't exist yet.
+// Run it before migrating models with enum columns.
+func CreateEnumTypes(db *gorm.DB) error {
+	for _, stmt := range EnumTypes {
+		err := db.Exec("DO $$ BEGIN " + stmt + "; EXCEPTION WHEN duplicate_object THEN NULL; END $$").Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+`
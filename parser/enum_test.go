@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/abiiranathan/apigen/config"
+)
+
+func TestParseEnums(t *testing.T) {
+	enums, err := ParseEnums([]string{"./testdata/models"})
+	if err != nil {
+		t.Fatalf("ParseEnums returned error: %v", err)
+	}
+
+	if len(enums) != 2 {
+		t.Fatalf("expected the Status and Priority enums, got %+v", enums)
+	}
+
+	status, priority := enums[0], enums[1]
+	if status.Name != "Status" || status.Kind != KindString || status.PkgName != "models" {
+		t.Errorf("unexpected string enum %+v", status)
+	}
+	if len(status.Values) != 2 || status.Values[0] != (EnumValue{"StatusActive", `"active"`}) || status.Values[1].Value != `"inactive"` {
+		t.Errorf("expected distinct values in declaration order, got %+v", status.Values)
+	}
+	if filepath.Base(status.Dir) != "models" {
+		t.Errorf("expected the package directory, got %q", status.Dir)
+	}
+	if filepath.Base(status.Pos.Filename) != "models.go" || status.Pos.Line == 0 {
+		t.Errorf("expected the position of the type, got %v", status.Pos)
+	}
+
+	if priority.Name != "Priority" || priority.Kind != KindInt {
+		t.Errorf("unexpected integer enum %+v", priority)
+	}
+	if len(priority.Values) != 2 || priority.Values[0].Value != "1" || priority.Values[1].Value != "2" {
+		t.Errorf("expected iota values, got %+v", priority.Values)
+	}
+
+	if got := status.CreateTypeSQL(); got != "CREATE TYPE status AS ENUM ('active', 'inactive')" {
+		t.Errorf("unexpected CREATE TYPE statement %q", got)
+	}
+	if got := priority.CreateTypeSQL(); got != "" {
+		t.Errorf("expected no Postgres type for integer enums, got %q", got)
+	}
+	if got := status.TypescriptUnion(); got != `"active" | "inactive"` {
+		t.Errorf("unexpected typescript union %q", got)
+	}
+	if got := priority.TypescriptUnion(); got != "1 | 2" {
+		t.Errorf("unexpected typescript union %q", got)
+	}
+}
+
+func TestParseWithEnums(t *testing.T) {
+	structs, enums, diagnostics, err := ParseWithEnums([]string{"./testdata/models"}, nil)
+	if err != nil {
+		t.Fatalf("ParseWithEnums returned error: %v", err)
+	}
+	wantStructs, wantDiagnostics, _ := Parse([]string{"./testdata/models"}, nil)
+	wantEnums, _ := ParseEnums([]string{"./testdata/models"})
+
+	if len(structs) != len(wantStructs) || len(diagnostics) != len(wantDiagnostics) {
+		t.Errorf("expected the structs and diagnostics of Parse, got %d structs and %v", len(structs), diagnostics)
+	}
+	if len(enums) != len(wantEnums) || enums[0].Name != wantEnums[0].Name {
+		t.Errorf("expected the enums of ParseEnums %+v, got %+v", wantEnums, enums)
+	}
+}
+
+func TestGenerateEnums(t *testing.T) {
+	enums, err := ParseEnums([]string{"./testdata/models"})
+	if err != nil {
+		t.Fatalf("ParseEnums returned error: %v", err)
+	}
+
+	files, err := generateEnumFiles(enums)
+	if err != nil {
+		t.Fatalf("generateEnumFiles returned error: %v", err)
+	}
+
+	content, ok := files[filepath.Join(enums[0].Dir, "models_enum.go")]
+	if !ok {
+		t.Fatalf("expected models_enum.go in the models directory, got %v", files)
+	}
+
+	out := string(content)
+	for _, want := range []string{
+		"// Code generated by \"apigen\"; DO NOT EDIT.\n\npackage models",
+		"func (e Status) ValidValues() []string {\n\treturn []string{\n\t\t\"active\",\n\t\t\"inactive\",\n\t}\n}",
+		"func (e Status) GormDataType() string {\n\treturn \"status\"\n}",
+		"case []byte:\n\t\t*e = Status(source)",
+		"func (e Priority) ValidValues() []int64 {\n\treturn []int64{\n\t\t1,\n\t\t2,\n\t}\n}",
+		"case int64:\n\t\t*e = Priority(source)",
+		"return int64(e), nil",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected enum file to contain %q\nGot:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "func (e Priority) GormDataType()") {
+		t.Errorf("expected integer enums to keep their column type")
+	}
+
+	cfg := &config.Config{}
+	cfg.Output.ServiceName = "services"
+	types, err := generateEnumTypes(cfg, enums)
+	if err != nil {
+		t.Fatalf("generateEnumTypes returned error: %v", err)
+	}
+	if !strings.Contains(string(types), "\"CREATE TYPE status AS ENUM ('active', 'inactive')\",") {
+		t.Errorf("expected the CREATE TYPE statement of Status\nGot:\n%s", types)
+	}
+}
@@ -60,7 +60,7 @@ func GenerateGORMServices(cfg *config.Config, structMetaData []StructMeta) (err
 
 	// Generate the postgres database connection helpers
 	dbPath := filepath.Join(targetDir, "database.go")
-	err = writeFile(dbPath, fmt.Appendf(nil, dbText, cfg.Output.ServiceName))
+	err = writeFile(dbPath, fmt.Appendf(nil, dbText, cfg.Output.ServiceName, cfg.Naming.TablePrefix, cfg.Naming.SingularTable))
 	if err != nil {
 		fmt.Printf("error writing to database.go helper %q: %v", dbPath, err)
 	}
@@ -1,6 +1,10 @@
 package parser
 
 import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
 
@@ -66,17 +70,20 @@ func TestGenerateGORMServicesUsesPerQueryConfig(t *testing.T) {
 	}
 
 	output := string(modelOutput)
-	if !strings.Contains(output, "return repo.getByID(id, repo.shouldPreload(true), options...)") {
-		t.Fatalf("expected Get to use the model-specific preload default")
+	for _, pattern := range []string{
+		`Get:\s+repo\.QuerySettings\{PreloadAll: true, RefetchAfterWrite: true\}`,     // model-specific preload default
+		`GetAll:\s+repo\.QuerySettings\{PreloadAll: false, RefetchAfterWrite: true\}`, // default query preload override
+		`Create:\s+repo\.QuerySettings\{PreloadAll: true, RefetchAfterWrite: false\}`,
+	} {
+		if !regexp.MustCompile(pattern).MatchString(output) {
+			t.Errorf("expected user service metadata to match %s\nGot:\n%s", pattern, output)
+		}
 	}
-	if !strings.Contains(output, "db := repo.applyConfiguredPreloads(repo.DB, repo.shouldPreload(false))") {
-		t.Fatalf("expected GetAll to use the default query preload override")
+	if !strings.Contains(output, "*repo.Repo[models.User, int]") {
+		t.Fatalf("expected generated repo to wrap the generic repository")
 	}
-	if strings.Contains(output, "tmpRecord, err := repo.getByID(user.ID, true, options...)") {
-		t.Fatalf("expected Create refetch block to be omitted when disabled")
-	}
-	if !strings.Contains(output, "preloadConfigured bool") {
-		t.Fatalf("expected generated repo to track runtime preload overrides")
+	if !strings.Contains(output, "repo.New(db, userMeta).WithHooks(hooks)") || !strings.Contains(output, "Hooks() *repo.HookSet[*models.User]") {
+		t.Errorf("expected user service to run and expose the hooks of its Service")
 	}
 
 	baseOutput, ok := generatedFiles["base_service.go"]
@@ -91,4 +98,545 @@ func TestGenerateGORMServicesUsesPerQueryConfig(t *testing.T) {
 	if !strings.Contains(base, "svc.Registry[\"User\"] = svc.UserService") {
 		t.Fatalf("expected User service to be registered in Service registry")
 	}
+	if !strings.Contains(base, "func (s *Service) Transaction(ctx context.Context, fn func(tx *Service) error, opts ...*TxOptions) error {") {
+		t.Fatalf("expected Service transactions to be generated in base service")
+	}
+	if !strings.Contains(base, "return fn(newService(tx, s.Hooks))") {
+		t.Fatalf("expected the Services of transactions to share the hooks of their Service")
+	}
+}
+
+func TestGenerateGORMServicesCompositePrimaryKey(t *testing.T) {
+	cfg := &config.Config{PreloadAll: true, PreloadDepth: 1}
+	cfg.Models.Pkgs = []string{testModelsPkg}
+	cfg.Output.ServiceName = "services"
+
+	structs := parseTestdata(t)
+	generatedFiles, err := generateGORMServiceFiles(structs, cfg)
+	if err != nil {
+		t.Fatalf("generateGORMServiceFiles returned error: %v", err)
+	}
+
+	enrollment := string(generatedFiles["enrollment_service.go"])
+	for _, want := range []string{
+		"type EnrollmentKey struct {",
+		"Get(id EnrollmentKey, options ...*Options) (*models.Enrollment, error)",
+		`clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: "visit_id"}, Value: id.VisitID}`,
+		"enrollment.PatientID = id.PatientID",
+	} {
+		if !strings.Contains(enrollment, want) {
+			t.Errorf("expected enrollment service to contain %q", want)
+		}
+	}
+
+	country := string(generatedFiles["country_service.go"])
+	for _, want := range []string{
+		"Get(id string, options ...*Options) (*models.Country, error)",
+		`clause.IN{Column: clause.Column{Table: clause.CurrentTable, Name: "code"}, Values: values}`,
+		"return country.Code",
+	} {
+		if !strings.Contains(country, want) {
+			t.Errorf("expected country service to contain %q", want)
+		}
+	}
+	if strings.Contains(country, "CountryKey") {
+		t.Errorf("expected no key struct for a single column primary key")
+	}
+
+	device := string(generatedFiles["device_service.go"])
+	for _, want := range []string{
+		`"github.com/abiiranathan/apigen/parser/testdata/ids"`,
+		"Get(id ids.UUID, options ...*Options) (*models.Device, error)",
+		"Delete(id ids.UUID) error",
+		"var deviceMeta = &repo.Meta[models.Device, ids.UUID]{",
+	} {
+		if !strings.Contains(device, want) {
+			t.Errorf("expected device service to contain %q", want)
+		}
+	}
+}
+
+func TestGenerateGORMServicesDirectives(t *testing.T) {
+	cfg := &config.Config{PreloadAll: true, PreloadDepth: 2}
+	cfg.Models.Pkgs = []string{testModelsPkg}
+	cfg.Output.ServiceName = "services"
+
+	structs := parseTestdata(t)
+	generatedFiles, err := generateGORMServiceFiles(structs, cfg)
+	if err != nil {
+		t.Fatalf("generateGORMServiceFiles returned error: %v", err)
+	}
+
+	member := string(generatedFiles["member_service.go"])
+	for _, want := range []string{
+		`Preloads: []string{"Role"},`, // Groups is not preloaded
+		`Omit:     []string{"Role", "Groups"},`,
+		`Hidden:   []string{"password"},`,
+		`Table:    "club_members",`,
+	} {
+		if !strings.Contains(member, want) {
+			t.Errorf("expected member service to contain %q\nGot:\n%s", want, member)
+		}
+	}
+
+	stats := string(generatedFiles["member_stats_service.go"])
+	if !strings.Contains(stats, "Preloads: []string{},") {
+		t.Errorf("expected no preloads for apigen:nopreload")
+	}
+	for _, method := range []string{"Create(", "Update(", "Delete(", "Begin("} {
+		if strings.Contains(stats, method) {
+			t.Errorf("expected no %s method for apigen:readonly", method)
+		}
+	}
+	if strings.Contains(stats, "\tTable:") {
+		t.Errorf("expected no explicit table without apigen:table")
+	}
+	if !strings.Contains(stats, "Get(id uint, options ...*Options) (*models.MemberStats, error)") {
+		t.Errorf("expected read methods for apigen:readonly")
+	}
+}
+
+func TestGenerateGORMServicesSoftDelete(t *testing.T) {
+	cfg := &config.Config{PreloadAll: true, PreloadDepth: 1, Context: config.ContextAPIBoth}
+	cfg.Models.Pkgs = []string{testModelsPkg}
+	cfg.Output.ServiceName = "services"
+
+	structs := parseTestdata(t)
+	generatedFiles, err := generateGORMServiceFiles(structs, cfg)
+	if err != nil {
+		t.Fatalf("generateGORMServiceFiles returned error: %v", err)
+	}
+
+	// Invoice embeds gorm.Model.
+	invoice := string(generatedFiles["invoice_service.go"])
+	for _, want := range []string{
+		`DeletedAt: "deleted_at",`,
+		"SoftDelete(id uint) error\n",
+		"Restore(id uint) error\n",
+		"ForceDelete(id uint) error\n",
+		"// Delete soft deletes invoice by primary key, see ForceDelete\n",
+		"// DeleteWhere soft deletes the invoices matching conditions.\n",
+		"GetDeleted(id uint, options ...*Options) (*models.Invoice, error)\n",
+		"FindDeleted(options ...*Options) (results []*models.Invoice, err error)\n",
+		"WithTrashed() invoiceService\n",
+		"SoftDeleteContext(ctx context.Context, id uint) error\n",
+		"return svc.withContext(ctx).FindDeleted(options...)",
+	} {
+		if !strings.Contains(invoice, want) {
+			t.Errorf("expected invoice service to contain %q\nGot:\n%s", want, invoice)
+		}
+	}
+	// The chain methods are documented as returning copies once, on the service type.
+	if n := strings.Count(invoice, "shared between goroutines"); n != 1 {
+		t.Errorf("expected the chain methods to be documented once, got %d times", n)
+	}
+
+	country := string(generatedFiles["country_service.go"])
+	for _, method := range []string{"DeletedAt", "SoftDelete", "Restore", "WithTrashed", "soft deletes"} {
+		if strings.Contains(country, method) {
+			t.Errorf("expected no %s without gorm.DeletedAt", method)
+		}
+	}
+}
+
+func TestGenerateGORMServicesContextAPI(t *testing.T) {
+	structs := parseTestdata(t)
+
+	generate := func(api config.ContextAPI, file string) string {
+		t.Helper()
+		cfg := &config.Config{PreloadAll: true, PreloadDepth: 1, Context: api}
+		cfg.Models.Pkgs = []string{testModelsPkg}
+		cfg.Output.ServiceName = "services"
+
+		generatedFiles, err := generateGORMServiceFiles(structs, cfg)
+		if err != nil {
+			t.Fatalf("generateGORMServiceFiles returned error: %v", err)
+		}
+		return string(generatedFiles[file])
+	}
+
+	none := generate(config.ContextAPINone, "country_service.go")
+	if strings.Contains(none, "context.Context") {
+		t.Errorf("expected no context methods by default")
+	}
+
+	only := generate(config.ContextAPIOnly, "country_service.go")
+	for _, want := range []string{
+		"\t\"context\"\n",
+		"Get(ctx context.Context, id string, options ...*Options) (*models.Country, error)\n",
+		"Delete(ctx context.Context, id string) error\n",
+		"Begin(ctx context.Context, opts ...*sql.TxOptions) (countryService, error)\n",
+		"func (svc *countryRepo) get(id string, options ...*Options) (*models.Country, error) {",
+		"return svc.withContext(ctx).get(id, options...)",
+		"return svc.withContext(ctx).deleteWhere(value, conds...)",
+		"Commit() error",
+	} {
+		if !strings.Contains(only, want) {
+			t.Errorf("expected context-only service to contain %q\nGot:\n%s", want, only)
+		}
+	}
+	if strings.Contains(only, "Get(id string, options ...*Options)") {
+		t.Errorf("expected no methods without context in the context-only service")
+	}
+
+	both := generate(config.ContextAPIBoth, "country_service.go")
+	for _, want := range []string{
+		"Get(id string, options ...*Options) (*models.Country, error)\n",
+		"GetContext(ctx context.Context, id string, options ...*Options) (*models.Country, error)\n",
+		"return svc.withContext(ctx).Get(id, options...)",
+		"return svc.withContext(ctx).GetPaginated(page, pageSize, options...)",
+	} {
+		if !strings.Contains(both, want) {
+			t.Errorf("expected service with both method sets to contain %q\nGot:\n%s", want, both)
+		}
+	}
+
+	// Read-only models only get context-first read methods.
+	stats := generate(config.ContextAPIBoth, "member_stats_service.go")
+	if !strings.Contains(stats, "FindManyContext(ctx context.Context") || strings.Contains(stats, "CreateContext(") {
+		t.Errorf("expected only read methods with context for read-only models\nGot:\n%s", stats)
+	}
+}
+
+// TestGeneratedServicesConcurrentUse runs testdata/race_test.go.txt with the race detector
+// against services generated for the test models.
+func TestGeneratedServicesConcurrentUse(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the generated services with the race detector")
+	}
+
+	cfg := &config.Config{PreloadAll: true, PreloadDepth: 1, Strict: true, Audit: []string{"Tag"}}
+	cfg.Models.Pkgs = []string{testModelsPkg}
+	cfg.Output.ServiceName = "services"
+
+	generatedFiles, err := generateGORMServiceFiles(parseTestdata(t), cfg)
+	if err != nil {
+		t.Fatalf("generateGORMServiceFiles returned error: %v", err)
+	}
+
+	// The package must be inside the module to import the test models.
+	dir, err := os.MkdirTemp("testdata", "services")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	testFiles, err := filepath.Glob(filepath.Join("testdata", "*_test.go.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range testFiles {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		generatedFiles[strings.TrimSuffix(filepath.Base(file), ".txt")] = content
+	}
+
+	for name, content := range generatedFiles {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command("go", "test", "-race", "-count=1", "./"+filepath.ToSlash(dir))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if strings.Contains(string(out), "-race requires cgo") {
+			t.Skipf("race detector unavailable: %s", out)
+		}
+		t.Fatalf("generated services tests failed: %v\n%s", err, out)
+	}
+}
+
+func TestGenerateGORMServicesUpsert(t *testing.T) {
+	cfg := &config.Config{PreloadAll: true, PreloadDepth: 1, Context: config.ContextAPIBoth}
+	cfg.Models.Pkgs = []string{testModelsPkg}
+	cfg.Output.ServiceName = "services"
+	cfg.Upsert = map[string][]string{"Tag": {"label"}}
+
+	structs := parseTestdata(t)
+	generatedFiles, err := generateGORMServiceFiles(structs, cfg)
+	if err != nil {
+		t.Fatalf("generateGORMServiceFiles returned error: %v", err)
+	}
+
+	for file, wants := range map[string][]string{
+		"subscription_service.go": {
+			`Conflict: []string{"plan", "account_id"},`,
+			"Upsert(subscription *models.Subscription, options ...*Options) error\n",
+			"UpsertMany(subscriptions *[]models.Subscription, options ...*Options) error\n",
+			"FirstOrCreate(subscription *models.Subscription, options ...*Options) error\n",
+			"FirstOrInit(subscription *models.Subscription, options ...*Options) error\n",
+			"UpsertContext(ctx context.Context, subscription *models.Subscription, options ...*Options) error\n",
+			"return svc.withContext(ctx).FirstOrInit(subscription, options...)",
+		},
+		"tag_service.go":     {`Conflict: []string{"label"},`},
+		"country_service.go": {"Upsert(country *models.Country, options ...*Options) error\n"},
+	} {
+		content := string(generatedFiles[file])
+		for _, want := range wants {
+			if !strings.Contains(content, want) {
+				t.Errorf("expected %s to contain %q\nGot:\n%s", file, want, content)
+			}
+		}
+	}
+
+	// Without conflict columns, Upsert conflicts on the primary key.
+	if strings.Contains(string(generatedFiles["country_service.go"]), "Conflict:") {
+		t.Errorf("expected no conflict columns for models without unique indexes")
+	}
+	if stats := string(generatedFiles["member_stats_service.go"]); strings.Contains(stats, "Upsert(") || strings.Contains(stats, "FirstOrCreate(") {
+		t.Errorf("expected no upsert methods for apigen:readonly")
+	}
+
+	cfg.Upsert = map[string][]string{"Tag": {"name"}}
+	if _, err := generateGORMServiceFiles(structs, cfg); err == nil || !strings.Contains(err.Error(), `"name"`) {
+		t.Errorf("expected an error for an unknown conflict column, got %v", err)
+	}
+}
+
+func TestGenerateGORMServicesKeyset(t *testing.T) {
+	cfg := &config.Config{PreloadAll: true, PreloadDepth: 1, Context: config.ContextAPIBoth}
+	cfg.Models.Pkgs = []string{testModelsPkg}
+	cfg.Output.ServiceName = "services"
+	cfg.Keyset = map[string][]string{"Invoice": {"-created_at"}}
+
+	structs := parseTestdata(t)
+	generatedFiles, err := generateGORMServiceFiles(structs, cfg)
+	if err != nil {
+		t.Fatalf("generateGORMServiceFiles returned error: %v", err)
+	}
+
+	invoice := string(generatedFiles["invoice_service.go"])
+	for _, want := range []string{
+		`Keyset:    []string{"-created_at"},`,
+		"GetPage(cursor string, limit int, options ...*Options) (*CursorResults[*models.Invoice], error)\n",
+		"GetPageContext(ctx context.Context, cursor string, limit int, options ...*Options) (*CursorResults[*models.Invoice], error)\n",
+		"WithCount() invoiceService\n",
+	} {
+		if !strings.Contains(invoice, want) {
+			t.Errorf("expected invoice service to contain %q\nGot:\n%s", want, invoice)
+		}
+	}
+	if strings.Contains(string(generatedFiles["country_service.go"]), "Keyset:") {
+		t.Errorf("expected no keyset without configuration")
+	}
+	if !strings.Contains(string(generatedFiles["base_service.go"]), "type CursorResults[T any] = repo.CursorResults[T]") {
+		t.Errorf("expected the CursorResults alias in the base service")
+	}
+
+	for model, want := range map[string]string{
+		"Member.-missing":  `has no column "missing"`,
+		"Member.password":  `column "password" is hidden`,
+		"Patient.nickname": `column "nickname" is nullable`,
+	} {
+		model, column, _ := strings.Cut(model, ".")
+		cfg.Keyset = map[string][]string{model: {column}}
+		if _, err := generateGORMServiceFiles(structs, cfg); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected an error containing %q, got %v", want, err)
+		}
+	}
+}
+
+func TestGenerateGORMServicesIteration(t *testing.T) {
+	cfg := &config.Config{PreloadAll: true, PreloadDepth: 1, Context: config.ContextAPIOnly}
+	cfg.Models.Pkgs = []string{testModelsPkg}
+	cfg.Output.ServiceName = "services"
+
+	structs := parseTestdata(t)
+	generatedFiles, err := generateGORMServiceFiles(structs, cfg)
+	if err != nil {
+		t.Fatalf("generateGORMServiceFiles returned error: %v", err)
+	}
+
+	enrollment := string(generatedFiles["enrollment_service.go"])
+	for _, want := range []string{
+		"\"iter\"\n",
+		"FindInBatches(ctx context.Context, batchSize int, fn func(enrollments []*models.Enrollment, batch int) error, options ...*Options) error\n",
+		"Each(ctx context.Context, batchSize int, options ...*Options) iter.Seq2[*models.Enrollment, error]\n",
+		"Iter(ctx context.Context, options ...*Options) iter.Seq2[*models.Enrollment, error]\n",
+		"return svc.withContext(ctx).iter(options...)",
+	} {
+		if !strings.Contains(enrollment, want) {
+			t.Errorf("expected enrollment service to contain %q\nGot:\n%s", want, enrollment)
+		}
+	}
+}
+
+func TestGenerateGORMServicesColumns(t *testing.T) {
+	cfg := &config.Config{PreloadAll: true, PreloadDepth: 1}
+	cfg.Models.Pkgs = []string{testModelsPkg}
+	cfg.Output.ServiceName = "services"
+
+	generatedFiles, err := generateGORMServiceFiles(parseTestdata(t), cfg)
+	if err != nil {
+		t.Fatalf("generateGORMServiceFiles returned error: %v", err)
+	}
+
+	patient := string(generatedFiles["patient_service.go"])
+	for _, want := range []string{
+		"\"time\"\n",
+		"ID        Column[models.PatientID]\n",
+		"Nickname  Column[string]\n", // *string
+		"BirthDate Column[time.Time]\n",
+		`BirthDate: Column[time.Time]{"birth_date"},`,
+	} {
+		if !strings.Contains(patient, want) {
+			t.Errorf("expected patient service to contain %q\nGot:\n%s", want, patient)
+		}
+	}
+	for _, field := range []string{"Visits", "Age", "secret"} {
+		if strings.Contains(patient, "\t"+field+" ") {
+			t.Errorf("expected no column descriptor for %s", field)
+		}
+	}
+
+	invoice := string(generatedFiles["invoice_service.go"])
+	if !strings.Contains(invoice, `OwnerName:  Column[string]{"owner_name"},`) {
+		t.Errorf("expected descriptors for the columns of embedded structs\nGot:\n%s", invoice)
+	}
+}
+
+func TestGenerateGORMServicesStrict(t *testing.T) {
+	cfg := &config.Config{Strict: true}
+	cfg.Models.Pkgs = []string{testModelsPkg}
+	cfg.Output.ServiceName = "services"
+
+	generatedFiles, err := generateGORMServiceFiles(parseTestdata(t), cfg)
+	if err != nil {
+		t.Fatalf("generateGORMServiceFiles returned error: %v", err)
+	}
+
+	base := string(generatedFiles["base_service.go"])
+	for _, want := range []string{
+		"const Strict = true\n",
+		"return repo.StrictOrder(db, order)",
+		"return repo.StrictSelect(db, columns...)",
+		"return repo.StrictGroup(db, group)",
+		"return repo.StrictILike(db, column, value)",
+		"return repo.StrictWhere(db, column, condition, vars...)",
+	} {
+		if !strings.Contains(base, want) {
+			t.Errorf("expected the base service to contain %q\nGot:\n%s", want, base)
+		}
+	}
+}
+
+func TestGenerateGORMServicesVersion(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Models.Pkgs = []string{testModelsPkg}
+	cfg.Output.ServiceName = "services"
+
+	generatedFiles, err := generateGORMServiceFiles(parseTestdata(t), cfg)
+	if err != nil {
+		t.Fatalf("generateGORMServiceFiles returned error: %v", err)
+	}
+
+	document := string(generatedFiles["document_service.go"])
+	for _, want := range []string{
+		`Version:  "revision",`,
+		"// Fails with repo.ErrStaleObject if the document was updated since its revision was read\n",
+	} {
+		if !strings.Contains(document, want) {
+			t.Errorf("expected document service to contain %q\nGot:\n%s", want, document)
+		}
+	}
+	if patient := string(generatedFiles["patient_service.go"]); strings.Contains(patient, "Version:") {
+		t.Errorf("expected no version column for models without one\nGot:\n%s", patient)
+	}
+}
+
+func TestGenerateGORMServicesAudit(t *testing.T) {
+	cfg := &config.Config{Context: config.ContextAPIBoth, Audit: []string{"Patient"}}
+	cfg.Models.Pkgs = []string{testModelsPkg}
+	cfg.Output.ServiceName = "services"
+
+	generatedFiles, err := generateGORMServiceFiles(parseTestdata(t), cfg)
+	if err != nil {
+		t.Fatalf("generateGORMServiceFiles returned error: %v", err)
+	}
+
+	patient := string(generatedFiles["patient_service.go"])
+	for _, want := range []string{
+		"Audit:     true,",
+		"History(id models.PatientID, options ...*Options) ([]*AuditEntry, error)",
+		"return svc.Repo.History(id, scope(options))",
+		"func (svc *patientRepo) HistoryContext(ctx context.Context, id models.PatientID, options ...*Options) ([]*AuditEntry, error) {",
+	} {
+		if !strings.Contains(patient, want) {
+			t.Errorf("expected patient service to contain %q\nGot:\n%s", want, patient)
+		}
+	}
+	if visit := string(generatedFiles["visit_service.go"]); strings.Contains(visit, "History") || strings.Contains(visit, "Audit:") {
+		t.Errorf("expected models left out of Audit not to be audited\nGot:\n%s", visit)
+	}
+
+	cfg.Audit = []string{"Contact"}
+	if _, err := generateGORMServiceFiles(parseTestdata(t), cfg); err == nil || !strings.Contains(err.Error(), "Contact has no primary key") {
+		t.Errorf("expected an error for audited models without a primary key, got %v", err)
+	}
+}
+
+func TestGenerateGORMServicesTenant(t *testing.T) {
+	cfg := &config.Config{Tenant: "account_id"}
+	cfg.Models.Pkgs = []string{testModelsPkg}
+	cfg.Output.ServiceName = "services"
+
+	generatedFiles, err := generateGORMServiceFiles(parseTestdata(t), cfg)
+	if err != nil {
+		t.Fatalf("generateGORMServiceFiles returned error: %v", err)
+	}
+
+	if post := string(generatedFiles["post_service.go"]); !strings.Contains(post, `Tenant:   "account_id",`) {
+		t.Errorf("expected post service to be scoped to the tenant\nGot:\n%s", post)
+	}
+	if tag := string(generatedFiles["tag_service.go"]); strings.Contains(tag, "Tenant:") {
+		t.Errorf("expected models without the tenant column not to be scoped\nGot:\n%s", tag)
+	}
+	base := string(generatedFiles["base_service.go"])
+	if !strings.Contains(base, "func NewService(db *gorm.DB) (*Service, error) {") ||
+		!strings.Contains(base, `if err := repo.UseTenancy(db, "account_id"); err != nil {`) {
+		t.Errorf("expected NewService to use tenancy\nGot:\n%s", base)
+	}
+
+	cfg.Tenant = ""
+	generatedFiles, err = generateGORMServiceFiles(parseTestdata(t), cfg)
+	if err != nil {
+		t.Fatalf("generateGORMServiceFiles returned error: %v", err)
+	}
+	if base := string(generatedFiles["base_service.go"]); strings.Contains(base, "UseTenancy") {
+		t.Errorf("expected no tenancy without Tenant\nGot:\n%s", base)
+	}
+}
+
+func TestGenerateGORMServicesParseQuery(t *testing.T) {
+	cfg := &config.Config{PreloadAll: true, PreloadDepth: 1}
+	cfg.Models.Pkgs = []string{testModelsPkg}
+	cfg.Output.ServiceName = "services"
+
+	generatedFiles, err := generateGORMServiceFiles(parseTestdata(t), cfg)
+	if err != nil {
+		t.Fatalf("generateGORMServiceFiles returned error: %v", err)
+	}
+
+	patient := string(generatedFiles["patient_service.go"])
+	for _, want := range []string{
+		"\"net/url\"\n",
+		`"birth_date": {Column: "birth_date", Kind: repo.QueryString},`,
+		"func ParsePatientQuery(query url.Values) (*Options, error) {",
+	} {
+		if !strings.Contains(patient, want) {
+			t.Errorf("expected patient service to contain %q\nGot:\n%s", want, patient)
+		}
+	}
+
+	member := string(generatedFiles["member_service.go"])
+	if strings.Contains(member, `"password": {`) {
+		t.Errorf("expected hidden fields not to be filtered on\nGot:\n%s", member)
+	}
+
+	services := string(generatedFiles["base_service.go"])
+	if !strings.Contains(services, "func ParseQuery(model string, query url.Values) (*Options, error) {") {
+		t.Errorf("expected the base service to dispatch ParseQuery by model\nGot:\n%s", services)
+	}
 }
@@ -0,0 +1,271 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// GormTag is the decoded gorm struct tag of a field.
+// Settings are matched case-insensitively like GORM does.
+type GormTag struct {
+	Ignore           bool       // "-" or "-:all": the field is not read or written by GORM
+	Column           string     // column:name
+	Type             string     // type:varchar(100)
+	PrimaryKey       bool       // primaryKey
+	AutoIncrement    bool       // autoIncrement
+	Default          string     // default:value
+	HasDefault       bool       // Whether a default was set. Default may be empty.
+	NotNull          bool       // not null
+	Unique           bool       // unique
+	Indexes          []IndexTag // index and uniqueIndex settings in declaration order
+	Constraint       string     // constraint:OnUpdate:CASCADE
+	ForeignKey       string     // foreignKey:RoleID
+	References       string     // references:ID
+	Many2Many        string     // many2many:user_tags (the join table)
+	JoinForeignKey   string     // joinForeignKey:UserID
+	JoinReferences   string     // joinReferences:TagID
+	Polymorphic      string     // polymorphic:Owner (OwnerID and OwnerType fields on the target)
+	PolymorphicType  string     // polymorphicType:Kind
+	PolymorphicID    string     // polymorphicId:RefID
+	PolymorphicValue string     // polymorphicValue:master (defaults to the owner table name)
+	Serializer       string     // serializer:json
+	Embedded         bool       // embedded
+	EmbeddedPrefix   string     // embeddedPrefix:author_
+}
+
+// IndexTag is an index declared with the index or uniqueIndex gorm settings.
+//
+//	gorm:"index:idx_name,unique,priority:2"
+type IndexTag struct {
+	Name     string // Index name. Empty when GORM derives it e.g idx_users_email
+	Unique   bool   // uniqueIndex or index:,unique
+	Priority int    // Position of the column in a composite index. Defaults to 10.
+}
+
+// UniqueIndexes returns the unique indexes declared on the field.
+func (g GormTag) UniqueIndexes() []IndexTag {
+	var indexes []IndexTag
+	for _, index := range g.Indexes {
+		if index.Unique {
+			indexes = append(indexes, index)
+		}
+	}
+	return indexes
+}
+
+// IsRelation reports whether the tag declares an association.
+// Associations following GORM's naming conventions need no tag, see StructMeta.Relations.
+func (g GormTag) IsRelation() bool {
+	return g.ForeignKey != "" || g.Many2Many != "" || g.Polymorphic != "" || g.PolymorphicType != ""
+}
+
+// tagSetting is a single key[:value] pair of a gorm tag.
+type tagSetting struct {
+	Key      string // Upper-cased key e.g "FOREIGNKEY"
+	Name     string // Key as written e.g "foreignKey"
+	Value    string
+	HasValue bool
+}
+
+// gormSettingKind describes the value expected by a gorm tag setting.
+type gormSettingKind int
+
+const (
+	settingFlag  gormSettingKind = iota // presence enables it, optionally :true or :false
+	settingValue                        // requires a non-empty value
+	settingAny                          // value is optional and free-form
+)
+
+// knownGormSettings lists every setting understood by GORM.
+var knownGormSettings = map[string]gormSettingKind{
+	"-":                      settingAny,
+	"->":                     settingAny,
+	"<-":                     settingAny,
+	"AUTOCREATETIME":         settingAny,
+	"AUTOINCREMENT":          settingFlag,
+	"AUTOINCREMENTINCREMENT": settingValue,
+	"AUTOUPDATETIME":         settingAny,
+	"BELONGSTO":              settingValue,
+	"CHECK":                  settingValue,
+	"COLUMN":                 settingValue,
+	"COMMENT":                settingAny,
+	"CONSTRAINT":             settingValue,
+	"DEFAULT":                settingAny,
+	"EMBEDDED":               settingFlag,
+	"EMBEDDEDPREFIX":         settingValue,
+	"FOREIGNKEY":             settingValue,
+	"INDEX":                  settingAny,
+	"JOINFOREIGNKEY":         settingValue,
+	"JOINREFERENCES":         settingValue,
+	"JSON":                   settingValue,
+	"MANY2MANY":              settingValue,
+	"NOT NULL":               settingFlag,
+	"NOTNULL":                settingFlag,
+	"POLYMORPHIC":            settingValue,
+	"POLYMORPHICID":          settingValue,
+	"POLYMORPHICTYPE":        settingValue,
+	"POLYMORPHICVALUE":       settingValue,
+	"PRECISION":              settingValue,
+	"PRIMARY_KEY":            settingFlag,
+	"PRIMARYKEY":             settingFlag,
+	"REFERENCES":             settingValue,
+	"SCALE":                  settingValue,
+	"SERIALIZER":             settingValue,
+	"SIZE":                   settingValue,
+	"TYPE":                   settingValue,
+	"UNIQUE":                 settingFlag,
+	"UNIQUEINDEX":            settingAny,
+}
+
+// ErrUnknownGormSetting is wrapped by errors of ParseGormTag for settings GORM does not
+// know about. GORM ignores them, they are likely typos.
+var ErrUnknownGormSetting = errors.New("unknown gorm setting")
+
+// splitGormTag splits the gorm tag value into settings in declaration order.
+// Like GORM, a separator preceded by a backslash is part of the value.
+func splitGormTag(tag string) []tagSetting {
+	var settings []tagSetting
+
+	parts := strings.Split(tag, ";")
+	for i := 0; i < len(parts); i++ {
+		part := parts[i]
+		for strings.HasSuffix(part, "\\") && i+1 < len(parts) {
+			i++
+			part = part[:len(part)-1] + ";" + parts[i]
+		}
+
+		name, value, hasValue := strings.Cut(part, ":")
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		settings = append(settings, tagSetting{
+			Key:      strings.ToUpper(name),
+			Name:     name,
+			Value:    value,
+			HasValue: hasValue,
+		})
+	}
+	return settings
+}
+
+// ParseGormTag decodes the gorm setting of struct tag tag (without backticks).
+// Unknown settings, missing values and malformed tags are returned as errors;
+// the returned GormTag holds every setting that could be decoded.
+func ParseGormTag(tag string) (GormTag, []error) {
+	var (
+		g    GormTag
+		errs []error
+	)
+
+	value, ok := reflect.StructTag(tag).Lookup("gorm")
+	if !ok {
+		if strings.Contains(tag, "gorm:") {
+			errs = append(errs, fmt.Errorf("malformed struct tag %q", tag))
+		}
+		return g, errs
+	}
+
+	for _, s := range splitGormTag(value) {
+		kind, known := knownGormSettings[s.Key]
+		if !known {
+			errs = append(errs, fmt.Errorf("%w %q", ErrUnknownGormSetting, s.Name))
+			continue
+		}
+
+		switch kind {
+		case settingValue:
+			if strings.TrimSpace(s.Value) == "" {
+				errs = append(errs, fmt.Errorf("gorm setting %q requires a value", s.Name))
+				continue
+			}
+		case settingFlag:
+			if s.HasValue && !strings.EqualFold(s.Value, "true") && !strings.EqualFold(s.Value, "false") {
+				errs = append(errs, fmt.Errorf("gorm setting %q expects true or false, got %q", s.Name, s.Value))
+				continue
+			}
+		}
+
+		flag := !s.HasValue || strings.EqualFold(s.Value, "true")
+		switch s.Key {
+		case "-":
+			// "-:migration" only skips migrations, the column is still read and written.
+			g.Ignore = !s.HasValue || strings.EqualFold(strings.TrimSpace(s.Value), "all")
+		case "COLUMN":
+			g.Column = strings.TrimSpace(s.Value)
+		case "TYPE":
+			g.Type = s.Value
+		case "PRIMARYKEY", "PRIMARY_KEY":
+			g.PrimaryKey = flag
+		case "AUTOINCREMENT":
+			g.AutoIncrement = flag
+		case "DEFAULT":
+			g.Default = s.Value
+			g.HasDefault = true
+		case "NOT NULL", "NOTNULL":
+			g.NotNull = flag
+		case "UNIQUE":
+			g.Unique = flag
+		case "INDEX", "UNIQUEINDEX":
+			index, err := parseIndexTag(s.Value, s.Key == "UNIQUEINDEX")
+			if err != nil {
+				errs = append(errs, fmt.Errorf("gorm setting %q: %w", s.Name, err))
+				continue
+			}
+			g.Indexes = append(g.Indexes, index)
+		case "CONSTRAINT":
+			g.Constraint = s.Value
+		case "FOREIGNKEY":
+			g.ForeignKey = strings.TrimSpace(s.Value)
+		case "REFERENCES":
+			g.References = strings.TrimSpace(s.Value)
+		case "MANY2MANY":
+			g.Many2Many = strings.TrimSpace(s.Value)
+		case "JOINFOREIGNKEY":
+			g.JoinForeignKey = strings.TrimSpace(s.Value)
+		case "JOINREFERENCES":
+			g.JoinReferences = strings.TrimSpace(s.Value)
+		case "POLYMORPHIC":
+			g.Polymorphic = strings.TrimSpace(s.Value)
+		case "POLYMORPHICTYPE":
+			g.PolymorphicType = strings.TrimSpace(s.Value)
+		case "POLYMORPHICID":
+			g.PolymorphicID = strings.TrimSpace(s.Value)
+		case "POLYMORPHICVALUE":
+			g.PolymorphicValue = strings.TrimSpace(s.Value)
+		case "SERIALIZER", "JSON":
+			g.Serializer = strings.TrimSpace(s.Value)
+		case "EMBEDDED":
+			g.Embedded = flag
+		case "EMBEDDEDPREFIX":
+			g.EmbeddedPrefix = s.Value
+		}
+	}
+	return g, errs
+}
+
+// parseIndexTag parses the value of an index or uniqueIndex setting
+// e.g "idx_name,unique,priority:2".
+func parseIndexTag(value string, unique bool) (IndexTag, error) {
+	name, options, _ := strings.Cut(value, ",")
+	index := IndexTag{Name: strings.TrimSpace(name), Unique: unique, Priority: 10}
+
+	for option := range strings.SplitSeq(options, ",") {
+		key, val, _ := strings.Cut(option, ":")
+		switch strings.ToUpper(strings.TrimSpace(key)) {
+		case "UNIQUE":
+			index.Unique = true
+		case "PRIORITY":
+			priority, err := strconv.Atoi(strings.TrimSpace(val))
+			if err != nil {
+				return index, fmt.Errorf("invalid index priority %q", val)
+			}
+			index.Priority = priority
+		}
+	}
+	return index, nil
+}
@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseGormTag(t *testing.T) {
+	tests := []struct {
+		name string
+		tag  string
+		want GormTag
+	}{
+		{
+			name: "no gorm tag",
+			tag:  `json:"name"`,
+			want: GormTag{},
+		},
+		{
+			name: "column settings",
+			tag:  `json:"code" gorm:"column:product_code;type:varchar(20);primaryKey;not null;default:'N/A'"`,
+			want: GormTag{Column: "product_code", Type: "varchar(20)", PrimaryKey: true, NotNull: true, Default: "'N/A'", HasDefault: true},
+		},
+		{
+			name: "keys are case insensitive",
+			tag:  `gorm:"PRIMARYKEY;autoincrement;Unique"`,
+			want: GormTag{PrimaryKey: true, AutoIncrement: true, Unique: true},
+		},
+		{
+			name: "explicit false",
+			tag:  `gorm:"primaryKey:false;autoIncrement:true"`,
+			want: GormTag{AutoIncrement: true},
+		},
+		{
+			name: "empty default",
+			tag:  `gorm:"default:"`,
+			want: GormTag{HasDefault: true},
+		},
+		{
+			name: "indexes",
+			tag:  `gorm:"index;uniqueIndex:idx_code_version,priority:2;index:idx_email,unique"`,
+			want: GormTag{Indexes: []IndexTag{
+				{Priority: 10},
+				{Name: "idx_code_version", Unique: true, Priority: 2},
+				{Name: "idx_email", Unique: true, Priority: 10},
+			}},
+		},
+		{
+			name: "belongs to",
+			tag:  `gorm:"foreignKey:RoleID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`,
+			want: GormTag{ForeignKey: "RoleID", References: "ID", Constraint: "OnUpdate:CASCADE,OnDelete:SET NULL"},
+		},
+		{
+			name: "many2many",
+			tag:  `gorm:"many2many:user_languages;joinForeignKey:UserID;joinReferences:LanguageID"`,
+			want: GormTag{Many2Many: "user_languages", JoinForeignKey: "UserID", JoinReferences: "LanguageID"},
+		},
+		{
+			name: "polymorphic",
+			tag:  `gorm:"polymorphicType:Kind;polymorphicId:OwnerID;polymorphicValue:master"`,
+			want: GormTag{PolymorphicType: "Kind", PolymorphicID: "OwnerID", PolymorphicValue: "master"},
+		},
+		{
+			name: "serializer and embedded",
+			tag:  `gorm:"serializer:json;embedded;embeddedPrefix:author_"`,
+			want: GormTag{Serializer: "json", Embedded: true, EmbeddedPrefix: "author_"},
+		},
+		{
+			name: "ignored",
+			tag:  `gorm:"-"`,
+			want: GormTag{Ignore: true},
+		},
+		{
+			name: "ignored from migrations only",
+			tag:  `gorm:"-:migration"`,
+			want: GormTag{},
+		},
+		{
+			name: "escaped separator",
+			tag:  `gorm:"default:a\\;b;not null"`,
+			want: GormTag{Default: "a;b", HasDefault: true, NotNull: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, errs := ParseGormTag(tt.tag)
+			if len(errs) != 0 {
+				t.Fatalf("unexpected errors: %v", errs)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseGormTag(%q)\n got: %+v\nwant: %+v", tt.tag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGormTagErrors(t *testing.T) {
+	tests := []struct {
+		tag     string
+		message string
+	}{
+		{tag: `gorm:"foreignkey"`, message: `"foreignkey" requires a value`},
+		{tag: `gorm:"column:"`, message: `"column" requires a value`},
+		{tag: `gorm:"primaryKey:yes"`, message: `expects true or false`},
+		{tag: `gorm:"foriegnKey:RoleID"`, message: `unknown gorm setting "foriegnKey"`},
+		{tag: `gorm:"index:,priority:first"`, message: `invalid index priority`},
+		{tag: `json:"role" gorm:foreignKey:RoleID`, message: `malformed struct tag`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			_, errs := ParseGormTag(tt.tag)
+			if len(errs) != 1 {
+				t.Fatalf("expected 1 error, got %v", errs)
+			}
+			if !strings.Contains(errs[0].Error(), tt.message) {
+				t.Fatalf("expected error containing %q, got %q", tt.message, errs[0])
+			}
+		})
+	}
+}
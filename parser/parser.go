@@ -5,13 +5,16 @@ package parser
 import (
 	"bytes"
 	_ "embed"
+	"errors"
 	"fmt"
 	"go/ast"
 	"go/format"
+	"go/token"
+	"go/types"
 	"io"
 	"log"
+	"reflect"
 	"slices"
-	"strconv"
 	"strings"
 	"text/template"
 
@@ -20,6 +23,7 @@ import (
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 	"golang.org/x/tools/go/packages"
+	"gorm.io/gorm/schema"
 )
 
 //go:embed services.gotmpl
@@ -27,250 +31,468 @@ var serviceTemplate []byte
 
 var enCaser = cases.Title(language.English)
 
-const (
-	skipTag         = "apigen:skip"
-	foreignKeyIdent = "foreignKey"
-	many2manyIdent  = "many2many"
-)
+// defaultNamer resolves table and column names the same way GORM does by default.
+var defaultNamer schema.Namer = schema.NamingStrategy{}
 
 // Field contains meta-data for each struct field.
 type Field struct {
-	Name     string // Field Exact name
-	Type     string // Full data type for the field
-	BaseType string // Base type stripped of [] or pointers
-	Tag      string // Unmodified struct tag.
-	Preload  bool   // Whether this is a foreignKey or many2many field to preload.
-	Parent   string // Parent struct Name
+	Name     string  // Field Exact name
+	Type     string  // Full data type for the field, relative to the model package
+	BaseType string  // Base type stripped of [] or pointers
+	Tag      string  // Struct tag without the enclosing backticks.
+	Gorm     GormTag // Decoded gorm tag
+	Preload  bool    // Whether this is a relation field to preload. See StructMeta.Relations
+	Parent   string  // Parent struct Name
+	Column   string  // Database column name including any embeddedPrefix e.g "author_name"
+	Hidden   bool    // Set with apigen:hidden. The column is written but never read nor exposed e.g passwords
+	Version  bool    // Set with apigen:version. The column is the version of optimistic locking, see StructMeta.VersionField
+
+	// PrimaryKey is true for fields tagged with primaryKey, or the ID field when none is tagged.
+	PrimaryKey bool
+
+	// EmbeddedPath is the Go selector of the named embedding field for fields flattened
+	// from a gorm:"embedded" struct e.g "Author". Empty for direct and promoted fields.
+	EmbeddedPath string
+
+	// Embedded is true for named gorm:"embedded" fields. They are not columns themselves;
+	// their columns follow them in StructMeta.Fields with EmbeddedPath set.
+	Embedded bool
+
+	QualifiedType string // Type qualified with package names e.g "models.Sex", usable outside the model package
+	Kind          Kind   // Underlying kind of Type with pointers removed e.g KindString for type Sex string
+	Underlying    string // Underlying basic type when Kind is basic e.g "string" for type Sex string
+	PkgPath       string // Import path of the package declaring BaseType e.g "time". Empty for builtin types
+	ElemType      string // Element type of slices, arrays and maps with pointers removed e.g "Tag" for []*Tag
+	ElemKind      Kind   // Underlying kind of ElemType
+	Nullable      bool   // Whether the field can hold NULL e.g pointers, sql.NullString and gorm.DeletedAt
+	TextMarshaler bool   // Whether the type implements encoding.TextMarshaler e.g time.Time
+	Comparable    bool   // Whether values of the type can be compared with == and used as map keys
 }
 
 // StructMeta contains metadata about the struct generated by the go/ast.
 type StructMeta struct {
 	Name    string  // Model name e.g User
-	PKType  string  // PKType e.g int, int64 etc
+	PKType  string  // PKType e.g int, int64 etc. Empty for composite or unsupported keys
 	Fields  []Field // Fields for struct fields that are builtin(only)
 	Package string  // Package name e.g "github.com/username/module/models"
-	Skip    bool    // Skip generating service for this struct
+	Skip    bool    // Skip generating service for this struct. Set with apigen:skip
+
+	// ReadOnly is set with apigen:readonly. Only read methods are generated for the model,
+	// like for models in the read-only packages of the configuration.
+	ReadOnly bool
+
+	// Preloads are the relations to preload set with apigen:preload=Role,Tags.
+	// They replace the relations found in Fields. See also NoPreload.
+	Preloads []string
+
+	// NoPreload is set with apigen:nopreload. No relation is preloaded.
+	NoPreload bool
+
+	// Table is the table name e.g "users", set with apigen:table=users, returned by the
+	// TableName method of the model or derived from Name by the naming strategy.
+	Table string
+
+	// TableOverride is true if Table is set with apigen:table. Generated GORM services
+	// then query Table explicitly since GORM doesn't know about it.
+	TableOverride bool
+
+	// Relations are the associations declared by Fields, in field order.
+	Relations []Relation
 }
 
-// Helper function to properly handle ast.Expr
-func formatSelectorExpr(expr ast.Expr) string {
-	switch x := expr.(type) {
-	case *ast.Ident:
-		return x.Name
-	case *ast.SelectorExpr:
-		return fmt.Sprintf("%s.%s", formatSelectorExpr(x.X), x.Sel.Name)
-	default:
-		return ""
+// loadMode is the information needed from packages.Load to type-check models.
+const loadMode = packages.NeedName | packages.NeedFiles | packages.NeedSyntax |
+	packages.NeedTypes | packages.NeedTypesInfo
+
+// Parse structs in packages modelPkgs and return Struct metadata about them.
+// Table and column names are resolved with namer, like GORM's Config.NamingStrategy.
+// A nil namer uses GORM's default naming strategy.
+//
+// Problems found in the models are returned as diagnostics, in package order.
+// Packages that fail to load or type-check are reported as error diagnostics
+// and skipped. The returned error is only set if the packages can't be loaded at all.
+func Parse(modelPkgs []string, namer schema.Namer) ([]StructMeta, []Diagnostic, error) {
+	pkgs, err := loadPackages(modelPkgs)
+	if err != nil {
+		return nil, nil, err
 	}
+	structs, diagnostics := parsePackages(pkgs, namer)
+	return structs, diagnostics, nil
 }
 
-// Helper function to properly forma *ast.ArrayType.
-func formatArrayType(typ *ast.ArrayType) string {
-	switch elemType := typ.Elt.(type) {
-	case *ast.Ident:
-		return elemType.Name
-	case *ast.SelectorExpr:
-		return formatSelectorExpr(elemType)
-	case *ast.ArrayType:
-		return "[]" + formatArrayType(elemType)
-	default:
-		return ""
+// ParseWithEnums is Parse also returning the enums of the packages, like ParseEnums,
+// loading the packages once.
+func ParseWithEnums(modelPkgs []string, namer schema.Namer) ([]StructMeta, []Enum, []Diagnostic, error) {
+	pkgs, err := loadPackages(modelPkgs)
+	if err != nil {
+		return nil, nil, nil, err
 	}
+	structs, diagnostics := parsePackages(pkgs, namer)
+	return structs, packagesEnums(pkgs), diagnostics, nil
 }
 
-// Given *ast.ArrayType, this function returns the length of the array or 0
-// if arrayType is nil or arrayType.Len == nil.
-func getArrayLen(arrayType *ast.ArrayType) int {
-	if arrayType == nil || arrayType.Len == nil {
-		return 0
+// parsePackages returns the structs of the loaded packages pkgs and their diagnostics.
+func parsePackages(pkgs []*packages.Package, namer schema.Namer) ([]StructMeta, []Diagnostic) {
+	if namer == nil {
+		namer = defaultNamer
 	}
 
-	if basicLit, ok := arrayType.Len.(*ast.BasicLit); ok {
-		if intValue, err := strconv.Atoi(basicLit.Value); err == nil {
-			return intValue
+	structSlice := []StructMeta{}
+
+	var diagnostics []Diagnostic
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			diagnostics = append(diagnostics, packageDiagnostics(pkg)...)
+			continue
 		}
+
+		p := &modelParser{pkg: pkg, namer: namer}
+
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				genDecl, ok := decl.(*ast.GenDecl)
+				if !ok || genDecl.Tok != token.TYPE {
+					continue
+				}
+
+				for _, spec := range genDecl.Specs {
+					t := spec.(*ast.TypeSpec)
+
+					// Doc comments of ungrouped declarations are attached to the GenDecl.
+					doc := t.Doc
+					if doc == nil && len(genDecl.Specs) == 1 {
+						doc = genDecl.Doc
+					}
+
+					if meta, ok := p.parseStruct(t, doc); ok {
+						structSlice = append(structSlice, meta)
+					}
+				}
+			}
+		}
+
+		diagnostics = append(diagnostics, p.diagnostics...)
 	}
-	return 0
+
+	inferRelations(structSlice, namer)
+	return structSlice, diagnostics
 }
 
-// Parse structs in package pkg and return Struct metadata about them.
-func Parse(modelPkgs []string) []StructMeta {
-	structSlice := []StructMeta{}
-	cfg := &packages.Config{Mode: packages.NeedSyntax | packages.NeedTypes}
+// loadPackages loads and type-checks the packages matching the patterns modelPkgs.
+func loadPackages(modelPkgs []string) ([]*packages.Package, error) {
+	cfg := &packages.Config{Mode: loadMode}
 
 	var pkgs []*packages.Package
 	for _, pkg := range modelPkgs {
 		parsedPkgs, err := packages.Load(cfg, pkg)
 		if err != nil {
-			panic(err)
+			return nil, fmt.Errorf("error loading package %s: %w", pkg, err)
 		}
 		pkgs = append(pkgs, parsedPkgs...)
 	}
+	return pkgs, nil
+}
 
-	for _, pkg := range pkgs {
-		for _, file := range pkg.Syntax {
-			ast.Inspect(file, func(node ast.Node) bool {
-				if t, ok := node.(*ast.TypeSpec); ok {
-					if stype, ok := t.Type.(*ast.StructType); ok {
-						meta := StructMeta{
-							Name:    t.Name.Name,
-							Package: pkg.String(),
-							Fields:  make([]Field, 0, len(stype.Fields.List)),
-							PKType:  "",
-							Skip:    false,
-						}
-
-						// Check if struct should be skipped by reading for doc comments
-						if t.Doc != nil {
-							for _, comment := range t.Doc.List {
-								if strings.Contains(comment.Text, skipTag) {
-									meta.Skip = true
-									log.Println("Skipping struct:", meta.Name)
-									break
-								}
-							}
-						}
-
-						for _, field := range stype.Fields.List {
-							var tagValue string
-							if field.Tag != nil {
-								tagValue = field.Tag.Value
-							}
-
-							// Check if it's a foreignKey or many to many based on tag
-							// foreignKey or many2many (GORM)
-							isFK := strings.Contains(tagValue, foreignKeyIdent)
-							isManyToMany := strings.Contains(tagValue, many2manyIdent)
-							Preload := isFK || isManyToMany
-
-							switch typ := field.Type.(type) {
-							case *ast.Ident:
-								for _, name := range field.Names {
-									if name.Name == "ID" {
-										meta.PKType = typ.Name
-									}
-									meta.Fields = append(meta.Fields,
-										Field{
-											Name:     name.Name,
-											Parent:   meta.Name,
-											Tag:      tagValue,
-											Type:     typ.Name,
-											BaseType: typ.Name,
-											Preload:  Preload,
-										})
-								}
-							case *ast.SelectorExpr:
-								fieldType := formatSelectorExpr(typ)
-								identName := fieldType
-								if _, ok := field.Type.(*ast.StarExpr); ok {
-									fieldType = "*" + fieldType
-								}
-
-								for _, name := range field.Names {
-									meta.Fields = append(meta.Fields,
-										Field{
-											Name:     name.Name,
-											Parent:   meta.Name,
-											Tag:      tagValue,
-											Type:     fieldType,
-											BaseType: identName,
-											Preload:  Preload,
-										})
-								}
-							case *ast.ArrayType:
-								fieldType := formatArrayType(typ)
-								arrLen := getArrayLen(typ)
-								var arrType string
-
-								if arrLen == 0 {
-									arrType = "[]" + fieldType
-								} else {
-									arrType = "[" + strconv.Itoa(arrLen) + "]" + fieldType
-								}
-
-								if _, ok := field.Type.(*ast.StarExpr); ok {
-									arrType = "*" + arrType
-								}
-
-								for _, name := range field.Names {
-									meta.Fields = append(meta.Fields,
-										Field{
-											Name:     name.Name,
-											Parent:   meta.Name,
-											Tag:      tagValue,
-											Type:     arrType,
-											BaseType: fieldType,
-											Preload:  Preload,
-										})
-								}
-							case *ast.StarExpr:
-								switch t := typ.X.(type) {
-								case *ast.Ident:
-									for _, name := range field.Names {
-										meta.Fields = append(meta.Fields,
-											Field{
-												Name:     name.Name,
-												Parent:   meta.Name,
-												Tag:      tagValue,
-												Type:     "*" + t.Name,
-												BaseType: t.Name,
-												Preload:  Preload,
-											})
-									}
-								case *ast.SelectorExpr:
-									identName := formatSelectorExpr(t)
-									fieldType := identName
-
-									if _, ok := t.X.(*ast.StarExpr); ok {
-										fieldType = "*" + fieldType
-									}
-
-									for _, name := range field.Names {
-										meta.Fields = append(meta.Fields,
-											Field{
-												Name:     name.Name,
-												BaseType: identName,
-												Parent:   meta.Name,
-												Tag:      tagValue,
-												Type:     fieldType,
-												Preload:  Preload,
-											})
-									}
-								case *ast.ArrayType:
-									if ident, ok := t.Elt.(*ast.Ident); ok {
-										arrLen := getArrayLen(t)
-										var arrType string
-										if arrLen == 0 {
-											arrType = "*[]" + ident.Name
-										} else {
-											arrType = "*[" + strconv.Itoa(arrLen) + "]" + ident.Name
-										}
-
-										for _, name := range field.Names {
-											meta.Fields = append(meta.Fields,
-												Field{
-													Name:     name.Name,
-													BaseType: ident.Name,
-													Parent:   meta.Name,
-													Tag:      tagValue,
-													Type:     arrType,
-													Preload:  Preload,
-												})
-										}
-									}
-								default:
-								}
-							default:
-							}
-						}
-						structSlice = append(structSlice, meta)
-					}
+// modelParser parses the models of a single type-checked package.
+type modelParser struct {
+	pkg         *packages.Package
+	namer       schema.Namer
+	diagnostics []Diagnostic
+
+	// comments maps the position of struct field names to their comments. Built on first use.
+	comments map[token.Pos][]*ast.CommentGroup
+}
+
+// report records a diagnostic for field (may be empty) of struct at pos.
+func (p *modelParser) report(pos token.Pos, severity Severity, structName, field, format string, args ...any) {
+	p.diagnostics = append(p.diagnostics, Diagnostic{
+		Pos:      p.pkg.Fset.Position(pos),
+		Severity: severity,
+		Struct:   structName,
+		Field:    field,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// parseStruct builds the StructMeta for type spec t.
+// It returns false if t does not declare a non-generic struct type.
+func (p *modelParser) parseStruct(t *ast.TypeSpec, doc *ast.CommentGroup) (StructMeta, bool) {
+	obj, ok := p.pkg.TypesInfo.Defs[t.Name].(*types.TypeName)
+	if !ok || obj.IsAlias() {
+		return StructMeta{}, false
+	}
+
+	named, ok := obj.Type().(*types.Named)
+	if !ok || named.TypeParams().Len() > 0 {
+		return StructMeta{}, false
+	}
+
+	stype, ok := named.Underlying().(*types.Struct)
+	if !ok {
+		return StructMeta{}, false
+	}
+
+	meta := StructMeta{
+		Name:    obj.Name(),
+		Package: p.pkg.PkgPath,
+		Fields:  make([]Field, 0, stype.NumFields()),
+		PKType:  "",
+		Skip:    false,
+		Table:   p.tableName(named, t.Pos()),
+	}
+
+	p.collectFields(&meta, stype, "", "", map[types.Type]bool{named: true})
+
+	// Directives come after the fields they may reference.
+	p.applyStructDirectives(&meta, doc)
+	if meta.Skip {
+		log.Println("Skipping struct:", meta.Name)
+	}
+
+	markPrimaryKeys(meta.Fields)
+
+	// PKType is only set for single-column keys that generated code can use as a parameter.
+	if pks := meta.PrimaryKeyFields(); len(pks) == 1 && isValidPKType(pks[0]) {
+		meta.PKType = pks[0].QualifiedType
+	}
+	return meta, true
+}
+
+// markPrimaryKeys sets Field.PrimaryKey like GORM does: fields tagged with primaryKey
+// form the key, otherwise a field named ID (possibly promoted from gorm.Model) is the key.
+func markPrimaryKeys(fields []Field) {
+	tagged := false
+	for i := range fields {
+		if fields[i].Gorm.PrimaryKey && isColumn(fields[i]) {
+			fields[i].PrimaryKey = true
+			tagged = true
+		}
+	}
+
+	if tagged {
+		return
+	}
+
+	for i := range fields {
+		if fields[i].Name == "ID" && fields[i].EmbeddedPath == "" && isColumn(fields[i]) {
+			fields[i].PrimaryKey = true
+			return
+		}
+	}
+}
+
+// isColumn reports whether f is stored in a column of the model table.
+func isColumn(f Field) bool {
+	return !f.Preload && !f.Embedded && !f.Gorm.Ignore
+}
+
+// PrimaryKeyFields returns the primary key fields of the model in declaration order.
+// For metadata built by hand without Field.PrimaryKey, the field named ID is returned.
+func (s StructMeta) PrimaryKeyFields() []Field {
+	var pks []Field
+	for _, f := range s.Fields {
+		if f.PrimaryKey {
+			pks = append(pks, f)
+		}
+	}
+
+	if len(pks) == 0 {
+		for _, f := range s.Fields {
+			if f.Name == "ID" && f.EmbeddedPath == "" && isColumn(f) {
+				return []Field{f}
+			}
+		}
+	}
+	return pks
+}
+
+// VersionField returns the version field of models updated with optimistic locking:
+// the field set with apigen:version or else an integer field named Version.
+func (s StructMeta) VersionField() (Field, bool) {
+	for _, f := range s.Fields {
+		if f.Version && isColumn(f) {
+			return f, true
+		}
+	}
+	for _, f := range s.Fields {
+		if f.Name == "Version" && isColumn(f) && isVersionType(f) {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// isVersionType reports whether f can hold the version of optimistic locking.
+func isVersionType(f Field) bool {
+	return (f.Kind == KindInt || f.Kind == KindUint) && !strings.HasPrefix(f.Type, "*")
+}
+
+// DeletedAt returns the gorm.DeletedAt field of models that GORM soft deletes.
+func (s StructMeta) DeletedAt() (Field, bool) {
+	for _, f := range s.Fields {
+		if f.PkgPath == "gorm.io/gorm" && strings.HasSuffix(f.BaseType, "DeletedAt") && isColumn(f) {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// UniqueColumns returns the columns of the first unique constraint of the model in
+// declaration order, set with the unique or uniqueIndex gorm settings. The columns of
+// composite indexes are sorted by priority. Returns nil for models without one.
+func (s StructMeta) UniqueColumns() []string {
+	type indexColumn struct {
+		column   string
+		priority int
+	}
+
+	var first string
+	var columns []indexColumn
+	for _, f := range s.Fields {
+		if !isColumn(f) {
+			continue
+		}
+		if first == "" && f.Gorm.Unique {
+			return []string{f.Column}
+		}
+		for _, index := range f.Gorm.UniqueIndexes() {
+			switch {
+			case first == "" && index.Name == "":
+				return []string{f.Column}
+			case first == "":
+				first = index.Name
+				fallthrough
+			case index.Name == first:
+				columns = append(columns, indexColumn{f.Column, index.Priority})
+			}
+		}
+	}
+
+	slices.SortStableFunc(columns, func(a, b indexColumn) int { return a.priority - b.priority })
+	var result []string
+	for _, c := range columns {
+		result = append(result, c.column)
+	}
+	return result
+}
+
+// JSONName returns the name of f in the json struct tag, "-" if it is left out of JSON
+// and empty without a name.
+func (f Field) JSONName() string {
+	name, _, _ := strings.Cut(reflect.StructTag(f.Tag).Get("json"), ",")
+	return name
+}
+
+// Selector returns the Go selector of f relative to its model e.g "Owner.Name".
+func (f Field) Selector() string {
+	return joinPath(f.EmbeddedPath, f.Name)
+}
+
+// collectFields appends the fields of stype to meta, flattening anonymous embedded structs
+// (e.g gorm.Model) and fields tagged with gorm:"embedded" in declaration order.
+// prefix is the column prefix set with embeddedPrefix and path the Go selector of the named
+// embedding field. seen holds the embedded types being expanded to guard against cycles.
+func (p *modelParser) collectFields(meta *StructMeta, stype *types.Struct, prefix, path string, seen map[types.Type]bool) {
+	for i := range stype.NumFields() {
+		v := stype.Field(i)
+		tagValue := stype.Tag(i)
+
+		gormTag, errs := ParseGormTag(tagValue)
+		for _, err := range errs {
+			severity := SeverityError
+			if errors.Is(err, ErrUnknownGormSetting) {
+				severity = SeverityWarning
+			}
+			p.report(v.Pos(), severity, meta.Name, v.Name(), "%v", err)
+		}
+
+		if gormTag.Ignore && v.Embedded() {
+			continue
+		}
+
+		if v.Embedded() || gormTag.Embedded {
+			if named, embeddedStruct, ok := embeddedStructOf(v.Type()); ok && !seen[named] {
+				embeddedPath := path
+				if !v.Embedded() {
+					embeddedPath = joinPath(path, v.Name())
+
+					// Keep the named field itself so JSON-aware generators can nest its fields.
+					field := Field{Name: v.Name(), Parent: meta.Name, Tag: tagValue, Gorm: gormTag, Embedded: true, EmbeddedPath: path}
+					resolveType(&field, v.Type(), p.pkg.Types)
+					meta.Fields = append(meta.Fields, field)
 				}
-				return true // Continue traversing the AST
-			})
+
+				seen[named] = true
+				p.collectFields(meta, embeddedStruct, prefix+gormTag.EmbeddedPrefix, embeddedPath, seen)
+				delete(seen, named)
+				continue
+			}
+		}
+
+		if !v.Exported() {
+			continue
+		}
+
+		column := gormTag.Column
+		if column == "" {
+			column = p.namer.ColumnName(meta.Table, v.Name())
 		}
+
+		field := Field{
+			Name:         v.Name(),
+			Parent:       meta.Name,
+			Tag:          tagValue,
+			Gorm:         gormTag,
+			Preload:      gormTag.IsRelation() && !gormTag.Ignore,
+			Column:       prefix + column,
+			EmbeddedPath: path,
+		}
+		resolveType(&field, v.Type(), p.pkg.Types)
+		p.applyFieldDirectives(&field, meta.Name, v.Pos())
+
+		// Maps and interfaces are only stored by GORM through a serializer.
+		if !isSupportedKind(field.Kind) && field.Gorm.Serializer == "" {
+			if !field.Gorm.Ignore {
+				p.report(v.Pos(), SeverityWarning, meta.Name, v.Name(),
+					"unsupported type %s is ignored, add a gorm serializer or gorm:\"-\" to the field", field.Type)
+			}
+			continue
+		}
+		meta.Fields = append(meta.Fields, field)
 	}
-	return structSlice
+}
+
+// embeddedStructOf returns the named type and struct of an embedded field type,
+// dereferencing pointers. It returns false if typ is not a struct.
+func embeddedStructOf(typ types.Type) (types.Type, *types.Struct, bool) {
+	typ, _ = deref(typ)
+	stype, ok := typ.Underlying().(*types.Struct)
+	return typ, stype, ok
+}
+
+// joinPath joins Go selectors with a dot.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// isValidPKType reports whether f can be used as a primary key parameter and map key
+// in generated code e.g int64, string, uuid.UUID or named types like type UserID string.
+func isValidPKType(f Field) bool {
+	if f.Nullable || !f.Comparable {
+		return false
+	}
+
+	switch f.Kind {
+	case KindComplex:
+		return false
+	case KindArray, KindStruct:
+		// Named types like uuid.UUID, not anonymous ones like [16]byte.
+		return f.PkgPath != "" && f.BaseType == f.Type
+	}
+	return f.Kind.IsBasic()
 }
 
 // Map takes a slice of StructMeta and returns a map with struct names as keys and StructMeta as values.
@@ -283,24 +505,291 @@ func Map(data []StructMeta) (m map[string]StructMeta) {
 }
 
 type tmplData struct {
-	PkgName      string     // Package name for the generated service.
-	ModelPkg     string     // Absolute name of package e.g "github.com/abiiranathan/todos/models"
-	ModelPkgs    []string   // Absolute names of all package e.g ["github.com/abiiranathan/todos/models"]
-	ModelPkgName string     // Name of package e.g "models"
-	ModelObj     StructMeta // The model metadata object
-	Model        string     // The struct name e.g "User"
-	OmitFields   []string   // ForeignKey fields to Omit during Update
-	Preloads     []string   // Stores fields to preload
+	PkgName      string                   // Package name for the generated service.
+	ModelPkg     string                   // Absolute name of package e.g "github.com/abiiranathan/todos/models"
+	ModelPkgs    []string                 // Absolute names of all package e.g ["github.com/abiiranathan/todos/models"]
+	ModelPkgName string                   // Name of package e.g "models"
+	ModelObj     StructMeta               // The model metadata object
+	Model        string                   // The struct name e.g "User"
+	OmitFields   []string                 // ForeignKey fields to Omit during Update
+	Hidden       []string                 // Columns of apigen:hidden fields, never read and not overwritten by Update
+	DeletedAt    string                   // Column of the gorm.DeletedAt field of soft deleted models. Empty otherwise
+	Version      string                   // Column of the optimistic locking version, see StructMeta.VersionField. Empty otherwise
+	Audit        bool                     // Whether writes are recorded in the audit table, see config.Config.Audit
+	Tenant       string                   // Column of config.Config.Tenant if the model has it. Empty otherwise
+	Conflict     []string                 // Conflict columns of the Upsert methods. Empty to use the primary key
+	Keyset       []string                 // Columns ordering GetPage, prefixed with "-" if descending. Empty to use the primary key
+	Iterable     bool                     // Whether the model has a keyset or primary key ordering FindInBatches, Each and Iter
+	Columns      []columnTemplateData     // Columns of the <Model>Fields descriptors
+	QueryFields  []queryFieldTemplateData // Fields filtered and sorted on by Parse<Model>Query
+	Imports      []string                 // Packages declaring the key and column types other than those always imported
+	Preloads     []string                 // Stores fields to preload
+	PK           primaryKeyTemplateData
 
 	DefaultAllocSize uint // Default size for slices
 
-	Queries           queryTemplateData
-	PkgReadOnly       bool // For SQL Views
+	Queries     queryTemplateData
+	PkgReadOnly bool // For SQL Views and apigen:readonly models
+
+	Context        config.ContextAPI           // Method set generated, see config.ContextAPI
+	ContextSuffix  string                      // Suffix of context-first methods e.g "Context" for GetContext
+	ContextMethods []contextMethodTemplateData // Methods with a context-first variant. Empty without context support
+
 	WritePKGDecl      bool
+	Strict            bool // Initial value of the Strict variable of the base file, see config.Config.Strict
 	SkipService       bool // Whether to skip creating this service
 	PreallocateSlices bool // Preallocate slices
 }
 
+// primaryKeyTemplateData describes the primary key parameter of generated methods.
+type primaryKeyTemplateData struct {
+	Type      string // Go type of the key parameter e.g "uint" or "UserRoleKey". Empty if the model has no usable key
+	Composite bool   // Whether Type is a generated key struct
+	Fields    []keyFieldTemplateData
+	Imports   []string // Packages declaring the key types other than the model package e.g "github.com/google/uuid"
+}
+
+// keyFieldTemplateData is a single column of a primary key.
+type keyFieldTemplateData struct {
+	Name     string // Field name in the key struct e.g "UserID"
+	Selector string // Selector of the field in the model e.g "UserID"
+	Column   string // Column name e.g "user_id"
+	Type     string // Go type qualified with the model package name
+}
+
+// newPrimaryKeyTemplateData returns the key of st. Composite keys are only supported
+// when every key column has a type usable as a parameter.
+func newPrimaryKeyTemplateData(st StructMeta) primaryKeyTemplateData {
+	var pk primaryKeyTemplateData
+
+	pks := st.PrimaryKeyFields()
+	if len(pks) == 0 || (len(pks) == 1 && st.PKType == "") {
+		return pk
+	}
+
+	for _, f := range pks {
+		if len(pks) > 1 && !isValidPKType(f) {
+			return primaryKeyTemplateData{}
+		}
+
+		typ := f.QualifiedType
+		if typ == "" {
+			typ = f.Type
+		}
+
+		column := f.Column
+		if column == "" {
+			column = defaultNamer.ColumnName("", f.Name)
+		}
+
+		if f.PkgPath != "" && f.PkgPath != st.Package && !slices.Contains(pk.Imports, f.PkgPath) {
+			pk.Imports = append(pk.Imports, f.PkgPath)
+		}
+
+		pk.Fields = append(pk.Fields, keyFieldTemplateData{
+			Name:     strings.ReplaceAll(f.Selector(), ".", ""),
+			Selector: f.Selector(),
+			Column:   column,
+			Type:     typ,
+		})
+	}
+
+	if len(pks) == 1 {
+		pk.Type = st.PKType
+		pk.Fields[0].Type = st.PKType
+	} else {
+		pk.Type = st.Name + "Key"
+		pk.Composite = true
+	}
+	return pk
+}
+
+// columnTemplateData is a field of the <Model>Fields column descriptors e.g Age Column[int].
+type columnTemplateData struct {
+	Name   string // Field name in the descriptors e.g "OwnerName" for Owner.Name
+	Column string // Column name e.g "owner_name"
+	Type   string // Go type of the values of the column qualified with package names, "any" if unusable
+}
+
+// newColumnTemplateData returns the column descriptors of st and the packages declaring their types.
+func newColumnTemplateData(st StructMeta) ([]columnTemplateData, []string) {
+	var columns []columnTemplateData
+	var imports []string
+	for _, f := range st.Fields {
+		if !isColumn(f) || !token.IsExported(f.Name) {
+			continue
+		}
+
+		column := f.Column
+		if column == "" {
+			column = defaultNamer.ColumnName("", f.Name)
+		}
+
+		typ := strings.TrimLeft(f.QualifiedType, "*")
+		_, typeArgs, generic := strings.Cut(typ, "[")
+		baseName := f.BaseType[strings.LastIndex(f.BaseType, ".")+1:]
+		switch {
+		case typ == "" || (generic && strings.Contains(typeArgs, ".")) || (f.PkgPath != "" && !token.IsExported(baseName)):
+			// Type arguments from other packages and unexported types can't be referenced.
+			typ = "any"
+		case f.PkgPath != "" && f.PkgPath != st.Package && !slices.Contains(imports, f.PkgPath):
+			imports = append(imports, f.PkgPath)
+		}
+
+		columns = append(columns, columnTemplateData{
+			Name:   strings.ReplaceAll(f.Selector(), ".", ""),
+			Column: column,
+			Type:   typ,
+		})
+	}
+	return columns, imports
+}
+
+// queryFieldTemplateData is an entry of the allowlist of Parse<Model>Query.
+type queryFieldTemplateData struct {
+	Name   string // Name of the field in query parameters, its column or JSON name
+	Column string // Column name e.g "created_at"
+	Kind   string // repo.QueryKind converting query values e.g "repo.QueryInt"
+}
+
+// newQueryFieldTemplateData returns the allowlist of Parse<Model>Query: the columns of st
+// by column and JSON name. Hidden fields and fields left out of JSON are not exposed.
+func newQueryFieldTemplateData(st StructMeta, columns []columnTemplateData) []queryFieldTemplateData {
+	var fields []queryFieldTemplateData
+	add := func(name, column string, kind Kind) {
+		if name == "" || slices.ContainsFunc(fields, func(f queryFieldTemplateData) bool { return f.Name == name }) {
+			return
+		}
+
+		queryKind := "repo.QueryString"
+		switch kind {
+		case KindInt:
+			queryKind = "repo.QueryInt"
+		case KindUint:
+			queryKind = "repo.QueryUint"
+		case KindFloat:
+			queryKind = "repo.QueryFloat"
+		case KindBool:
+			queryKind = "repo.QueryBool"
+		}
+		fields = append(fields, queryFieldTemplateData{Name: name, Column: column, Kind: queryKind})
+	}
+
+	for _, c := range columns {
+		i := slices.IndexFunc(st.Fields, func(f Field) bool { return strings.ReplaceAll(f.Selector(), ".", "") == c.Name })
+		f := st.Fields[i]
+		jsonName := f.JSONName()
+		if f.Hidden || jsonName == "-" {
+			continue
+		}
+		add(c.Column, c.Column, f.Kind)
+		add(jsonName, c.Column, f.Kind)
+	}
+	return fields
+}
+
+// contextMethodTemplateData is a service method with a context-first variant
+// e.g Get(ctx context.Context, id uint, options ...*Options).
+type contextMethodTemplateData struct {
+	Name    string // Method name e.g "Get"
+	Doc     string // Documentation following the method name
+	Params  string // Parameters after ctx e.g "id uint, options ...*Options"
+	Args    string // Params passed on e.g "id, options..."
+	Results string // Results e.g "(*models.User, error)"
+}
+
+// newContextMethods returns the context-first methods of the service of data.Model
+// for data.Context, following the read-only setting and primary key of the model.
+func newContextMethods(data tmplData) []contextMethodTemplateData {
+	if data.Context != config.ContextAPIOnly && data.Context != config.ContextAPIBoth {
+		return nil
+	}
+
+	ident := strings.ToLower(data.Model)
+	model := "*" + data.ModelPkgName + "." + data.Model
+	pk := data.PK.Type
+
+	type method struct {
+		contextMethodTemplateData
+		write, needsPK, softDelete bool
+	}
+
+	methods := []method{
+		{contextMethodTemplateData{"Create", "creates a new " + ident + ".",
+			ident + " " + model + ", options ...*Options", ident + ", options...", "error"}, true, false, false},
+		{contextMethodTemplateData{"CreateMany", "creates multiple " + ident + "s.",
+			ident + "s *[]" + model[1:] + ", options ...*Options", ident + "s, options...", "error"}, true, false, false},
+		{contextMethodTemplateData{"Update", "updates " + ident + " with all the fields. Uses gorm.DB.Save()",
+			"id " + pk + ", " + ident + " " + model + ", options ...*Options", "id, " + ident + ", options...", "(" + model + ", error)"}, true, true, false},
+		// Upsert conflicts on the primary key of models without conflict columns.
+		{contextMethodTemplateData{"Upsert", "creates " + ident + " or updates the existing " + ident + " it conflicts with.",
+			ident + " " + model + ", options ...*Options", ident + ", options...", "error"}, true, len(data.Conflict) == 0, false},
+		{contextMethodTemplateData{"UpsertMany", "upserts multiple " + ident + "s.",
+			ident + "s *[]" + model[1:] + ", options ...*Options", ident + "s, options...", "error"}, true, len(data.Conflict) == 0, false},
+		{contextMethodTemplateData{"FirstOrCreate", "finds the first " + ident + " matching the non-zero fields of " + ident + " or creates it.",
+			ident + " " + model + ", options ...*Options", ident + ", options...", "error"}, true, false, false},
+		{contextMethodTemplateData{"UpdateColumn", "updates a single column with specified conditions.",
+			"columnName string, value any, query string, args ...any", "columnName, value, query, args...", "error"}, true, false, false},
+		{contextMethodTemplateData{"PartialUpdate", "only updates fields of " + ident + " with non-zero values. Returns the updated " + ident + ".",
+			"id " + pk + ", " + ident + " " + model[1:] + ", options ...*Options", "id, " + ident + ", options...", "(" + model + ", error)"}, true, true, false},
+		{contextMethodTemplateData{"PartialUpdateWithMap", "only updates the columns in data. Returns the updated " + ident + ".",
+			"id " + pk + ", data map[string]any, options ...*Options", "id, data, options...", "(" + model + ", error)"}, true, true, false},
+		{contextMethodTemplateData{"Delete", "permanently deletes " + ident + " from the database by primary key.",
+			"id " + pk, "id", "error"}, true, true, false},
+		{contextMethodTemplateData{"DeleteWhere", "permanently deletes " + ident + "s from the database matching conditions.",
+			"value string, conds ...any", "value, conds...", "error"}, true, false, false},
+		{contextMethodTemplateData{"SoftDelete", "soft deletes " + ident + " by primary key.",
+			"id " + pk, "id", "error"}, true, true, true},
+		{contextMethodTemplateData{"Restore", "restores the soft deleted " + ident + " by primary key.",
+			"id " + pk, "id", "error"}, true, true, true},
+		{contextMethodTemplateData{"ForceDelete", "permanently deletes " + ident + ", soft deleted or not, by primary key.",
+			"id " + pk, "id", "error"}, true, true, true},
+		{contextMethodTemplateData{"Begin", "returns a " + ident + "Service running all queries in a transaction with ctx.",
+			"opts ...*sql.TxOptions", "opts...", "(" + ident + "Service, error)"}, true, false, false},
+		{contextMethodTemplateData{"Get", "gets a single " + ident + " by id (primary key).",
+			"id " + pk + ", options ...*Options", "id, options...", "(" + model + ", error)"}, false, true, false},
+		{contextMethodTemplateData{"GetAll", "retrieves all " + ident + "s from the database.",
+			"options ...*Options", "options...", "([]" + model + ", error)"}, false, false, false},
+		{contextMethodTemplateData{"Count", "returns the number of records matching the query.",
+			"options ...*Options", "options...", "(int64, error)"}, false, false, false},
+		{contextMethodTemplateData{"FindOne", "finds the first record matching the options.",
+			"options ...*Options", "options...", "(" + model + ", error)"}, false, false, false},
+		{contextMethodTemplateData{"FindMany", "finds all records matching the options.",
+			"options ...*Options", "options...", "([]" + model + ", error)"}, false, false, false},
+		{contextMethodTemplateData{"GetPaginated", "retrieves a paginated list of " + ident + "s.",
+			"page int, pageSize int, options ...*Options", "page, pageSize, options...", "(*PaginatedResults[" + model + "], error)"}, false, false, false},
+		{contextMethodTemplateData{"FirstOrInit", "finds the first " + ident + " matching the non-zero fields of " + ident + ".",
+			ident + " " + model + ", options ...*Options", ident + ", options...", "error"}, false, false, false},
+		{contextMethodTemplateData{"GetPage", "retrieves the page of " + ident + "s following cursor.",
+			"cursor string, limit int, options ...*Options", "cursor, limit, options...", "(*CursorResults[" + model + "], error)"}, false, false, false},
+		// Models without a primary key are only iterable with a keyset.
+		{contextMethodTemplateData{"FindInBatches", "calls fn with the " + ident + "s matching the options, batchSize at a time.",
+			"batchSize int, fn func(" + ident + "s []" + model + ", batch int) error, options ...*Options", "batchSize, fn, options...", "error"}, false, !data.Iterable, false},
+		{contextMethodTemplateData{"Each", "returns an iterator over the " + ident + "s matching the options, read batchSize at a time.",
+			"batchSize int, options ...*Options", "batchSize, options...", "iter.Seq2[" + model + ", error]"}, false, !data.Iterable, false},
+		{contextMethodTemplateData{"Iter", "returns an iterator over the " + ident + "s matching the options.",
+			"options ...*Options", "options...", "iter.Seq2[" + model + ", error]"}, false, !data.Iterable, false},
+		{contextMethodTemplateData{"GetDeleted", "gets a soft deleted " + ident + " by id (primary key).",
+			"id " + pk + ", options ...*Options", "id, options...", "(" + model + ", error)"}, false, true, true},
+		{contextMethodTemplateData{"FindDeleted", "finds the soft deleted " + ident + "s matching the options.",
+			"options ...*Options", "options...", "([]" + model + ", error)"}, false, false, true},
+	}
+
+	if data.Audit {
+		methods = append(methods, method{contextMethodTemplateData{"History", "returns the audit entries of the " + ident + " with primary key id, oldest first.",
+			"id " + pk + ", options ...*Options", "id, options...", "([]*AuditEntry, error)"}, false, true, false})
+	}
+
+	var result []contextMethodTemplateData
+	for _, m := range methods {
+		if (m.write && data.PkgReadOnly) || (m.needsPK && pk == "") || (m.softDelete && data.DeletedAt == "") {
+			continue
+		}
+		result = append(result, m.contextMethodTemplateData)
+	}
+	return result
+}
+
 type queryMethodTemplateData struct {
 	PreloadAll        bool
 	RefetchAfterWrite bool
@@ -310,6 +799,7 @@ type queryTemplateData struct {
 	Get                  queryMethodTemplateData
 	GetAll               queryMethodTemplateData
 	GetPaginated         queryMethodTemplateData
+	GetPage              queryMethodTemplateData
 	FindOne              queryMethodTemplateData
 	FindMany             queryMethodTemplateData
 	Create               queryMethodTemplateData
@@ -317,6 +807,47 @@ type queryTemplateData struct {
 	Update               queryMethodTemplateData
 	PartialUpdate        queryMethodTemplateData
 	PartialUpdateWithMap queryMethodTemplateData
+	Upsert               queryMethodTemplateData
+	UpsertMany           queryMethodTemplateData
+	FirstOrCreate        queryMethodTemplateData
+	FirstOrInit          queryMethodTemplateData
+}
+
+// upsertConflict returns the conflict columns of the Upsert methods of st,
+// configured in apigen.toml or those of its first unique constraint.
+func upsertConflict(st StructMeta, cfg *config.Config) ([]string, error) {
+	columns, ok := cfg.Upsert[st.Name]
+	if !ok {
+		return st.UniqueColumns(), nil
+	}
+
+	for _, column := range columns {
+		if !slices.ContainsFunc(st.Fields, func(f Field) bool { return isColumn(f) && f.Column == column }) {
+			return nil, fmt.Errorf("error: Upsert.%s: %s has no column %q", st.Name, st.Name, column)
+		}
+	}
+	return columns, nil
+}
+
+// keysetColumns returns the columns ordering the pages of GetPage configured for st in apigen.toml.
+// Hidden columns are never read, so they can't be used in cursors.
+func keysetColumns(st StructMeta, cfg *config.Config) ([]string, error) {
+	columns := cfg.Keyset[st.Name]
+	for _, column := range columns {
+		name := strings.TrimPrefix(column, "-")
+		i := slices.IndexFunc(st.Fields, func(f Field) bool { return isColumn(f) && f.Column == name })
+		if i < 0 {
+			return nil, fmt.Errorf("error: Keyset.%s: %s has no column %q", st.Name, st.Name, name)
+		}
+		if st.Fields[i].Hidden {
+			return nil, fmt.Errorf("error: Keyset.%s: column %q is hidden", st.Name, name)
+		}
+		if st.Fields[i].Nullable {
+			// NULL values compare neither greater nor less, their rows would be skipped.
+			return nil, fmt.Errorf("error: Keyset.%s: column %q is nullable", st.Name, name)
+		}
+	}
+	return columns, nil
 }
 
 func packageReadOnly(cfg *config.Config, pkg string) bool {
@@ -347,17 +878,48 @@ func generateGORMServiceFiles(structs []StructMeta, cfg *config.Config) (map[str
 
 		preloadFields := preloads[st.Name]
 
+		// Associations are omitted from writes, including those not preloaded with apigen:preload.
 		omitFields := []string{}
-		for _, f := range preloadFields {
-			var prefix = f
-			if strings.Contains(f, ".") {
-				prefix = strings.Split(f, ".")[0]
+		hiddenColumns := []string{}
+		for _, f := range st.Fields {
+			if f.Preload && f.EmbeddedPath == "" && !slices.Contains(omitFields, f.Name) {
+				omitFields = append(omitFields, f.Name)
 			}
-
-			if !slices.Contains(omitFields, prefix) {
-				omitFields = append(omitFields, prefix)
+			if f.Hidden && isColumn(f) {
+				hiddenColumns = append(hiddenColumns, f.Column)
 			}
+		}
+
+		var deletedAt string
+		if f, ok := st.DeletedAt(); ok {
+			deletedAt = f.Column
+		}
+
+		var version string
+		if f, ok := st.VersionField(); ok {
+			version = f.Column
+		}
+
+		conflict, err := upsertConflict(st, cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		keyset, err := keysetColumns(st, cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		audit := slices.Contains(cfg.Audit, st.Name)
+		if audit && len(st.PrimaryKeyFields()) == 0 {
+			return nil, fmt.Errorf("error: Audit: %s has no primary key to record", st.Name)
+		}
 
+		var tenant string
+		for _, f := range st.Fields {
+			if cfg.Tenant != "" && f.Column == cfg.Tenant && isColumn(f) {
+				tenant = f.Column
+			}
 		}
 
 		data := tmplData{
@@ -366,17 +928,44 @@ func generateGORMServiceFiles(structs []StructMeta, cfg *config.Config) (map[str
 			ModelPkgs:    cfg.Models.Pkgs,
 			ModelPkgName: modelPkgName,
 			Queries:      newQueryTemplateData(cfg, st.Name),
-			PkgReadOnly:  packageReadOnly(cfg, st.Package),
+			PkgReadOnly:  packageReadOnly(cfg, st.Package) || st.ReadOnly,
 			ModelObj:     st,
 			Model:        st.Name,
 			WritePKGDecl: false,
 			Preloads:     preloadFields,
+			PK:           newPrimaryKeyTemplateData(st),
 			OmitFields:   omitFields,
+			Hidden:       hiddenColumns,
+			DeletedAt:    deletedAt,
+			Version:      version,
+			Audit:        audit && !(packageReadOnly(cfg, st.Package) || st.ReadOnly),
+			Tenant:       tenant,
+			Conflict:     conflict,
+			Keyset:       keyset,
+			Iterable:     len(keyset) > 0 || len(st.PrimaryKeyFields()) > 0,
 			SkipService:  false,
+			Context:      cfg.Context,
+		}
+		if data.Context == config.ContextAPIBoth {
+			data.ContextSuffix = "Context"
+		}
+		data.ContextMethods = newContextMethods(data)
+
+		var columnImports []string
+		data.Columns, columnImports = newColumnTemplateData(st)
+		data.QueryFields = newQueryFieldTemplateData(st, data.Columns)
+		for _, pkg := range slices.Concat(data.PK.Imports, columnImports) {
+			// The header already imports the model package, gorm, clause for models with a key
+			// and database/sql for writable models.
+			always := pkg == st.Package || pkg == "gorm.io/gorm" || (pkg == "gorm.io/gorm/clause" && data.PK.Type != "") ||
+				(pkg == "database/sql" && !data.PkgReadOnly)
+			if !always && !slices.Contains(data.Imports, pkg) {
+				data.Imports = append(data.Imports, pkg)
+			}
 		}
 
 		buf := new(bytes.Buffer)
-		err := renderModelServiceHeader(buf, data)
+		err = renderModelServiceHeader(buf, data)
 		if err != nil {
 			return nil, err
 		}
@@ -399,6 +988,7 @@ func generateGORMServiceFiles(structs []StructMeta, cfg *config.Config) (map[str
 		PkgName:      cfg.Output.ServiceName,
 		ModelPkgs:    cfg.Models.Pkgs,
 		WritePKGDecl: true,
+		Strict:       cfg.Strict,
 		SkipService:  true,
 	}
 
@@ -428,7 +1018,8 @@ func generateGORMServiceFiles(structs []StructMeta, cfg *config.Config) (map[str
 		}
 	}
 
-	if err := tmpl.Execute(baseBuf, services); err != nil {
+	mainData := mainTemplateData{Services: services, Tenant: cfg.Tenant}
+	if err := tmpl.Execute(baseBuf, mainData); err != nil {
 		return nil, err
 	}
 
@@ -448,9 +1039,15 @@ package {{.PkgName}}
 
 import (
 	"{{.ModelPkg}}"
+	{{if .ContextMethods}}"context"
+	{{end}}{{if .Iterable}}"iter"
+	{{end}}"net/url"
 	{{if not .PkgReadOnly}}"database/sql"
 	{{end}}"gorm.io/gorm"
-	"math"
+	{{if .PK.Type}}"gorm.io/gorm/clause"
+	{{end}}"github.com/abiiranathan/apigen/repo"
+	{{range .Imports}}"{{.}}"
+	{{end}}
 )
 
 `
@@ -472,6 +1069,7 @@ func newQueryTemplateData(cfg *config.Config, model string) queryTemplateData {
 		Get:                  newQueryMethodTemplateData(cfg.QueryConfig(model, "Get")),
 		GetAll:               newQueryMethodTemplateData(cfg.QueryConfig(model, "GetAll")),
 		GetPaginated:         newQueryMethodTemplateData(cfg.QueryConfig(model, "GetPaginated")),
+		GetPage:              newQueryMethodTemplateData(cfg.QueryConfig(model, "GetPage")),
 		FindOne:              newQueryMethodTemplateData(cfg.QueryConfig(model, "FindOne")),
 		FindMany:             newQueryMethodTemplateData(cfg.QueryConfig(model, "FindMany")),
 		Create:               newQueryMethodTemplateData(cfg.QueryConfig(model, "Create")),
@@ -479,6 +1077,10 @@ func newQueryTemplateData(cfg *config.Config, model string) queryTemplateData {
 		Update:               newQueryMethodTemplateData(cfg.QueryConfig(model, "Update")),
 		PartialUpdate:        newQueryMethodTemplateData(cfg.QueryConfig(model, "PartialUpdate")),
 		PartialUpdateWithMap: newQueryMethodTemplateData(cfg.QueryConfig(model, "PartialUpdateWithMap")),
+		Upsert:               newQueryMethodTemplateData(cfg.QueryConfig(model, "Upsert")),
+		UpsertMany:           newQueryMethodTemplateData(cfg.QueryConfig(model, "UpsertMany")),
+		FirstOrCreate:        newQueryMethodTemplateData(cfg.QueryConfig(model, "FirstOrCreate")),
+		FirstOrInit:          newQueryMethodTemplateData(cfg.QueryConfig(model, "FirstOrInit")),
 	}
 }
 
@@ -503,6 +1105,14 @@ func parseTemplate(w io.Writer, data tmplData) error {
 			}
 			return strings.Join(quotedSlice, ", ")
 		},
+		// impl returns the name of the method implementing method name. Methods taking
+		// no context are unexported when only context-first methods are generated.
+		"impl": func(name string) string {
+			if data.Context == config.ContextAPIOnly {
+				return strings.ToLower(name[:1]) + name[1:]
+			}
+			return name
+		},
 	}).Parse(string(serviceTemplate))
 
 	if err != nil {
@@ -516,14 +1126,27 @@ func parseTemplate(w io.Writer, data tmplData) error {
 	return nil
 }
 
+// mainTemplateData is the data of mainSVCTmpl.
+type mainTemplateData struct {
+	Services []string // Models with a generated service
+	Tenant   string   // Column of config.Config.Tenant, empty if disabled
+}
+
 var mainSVCTmpl = `
 // Service embeds all generated services
 type Service struct {
-	{{range .}}
+	{{range .Services}}
 	{{.}}Service  {{.| ToLower }}Service
 	{{- end}}
 
 	DB *gorm.DB
+
+	// Hooks run around the operations of all models, see Hooks.
+	// They are shared by the Services of transactions.
+	Hooks *Hooks
+
+	// Registry maps model names to their generated services.
+	Registry map[string]any
 }
 
 // Begin starts a transaction and returns a transactional Service.
@@ -532,7 +1155,7 @@ func (s *Service) Begin() (*Service, error){
 	if tx.Error != nil {
 		return nil, tx.Error
 	}
-	return NewService(tx), nil
+	return newService(tx, s.Hooks), nil
 }
 
 // Commit commits the transaction associated with Service DB.
@@ -545,14 +1168,66 @@ func (s *Service) Rollback() error {
 	return s.DB.Rollback().Error
 }
 
+// Transaction runs fn with a Service whose queries run in a transaction, committed if fn
+// returns nil and rolled back if it returns an error or panics. Transactions started with
+// the Service of fn are nested in SAVEPOINTs. Pass TxOptions to retry the transactions
+// failing with serialization failures or deadlocks, fn must then be safe to rerun e.g
+//
+//	err := svc.Transaction(ctx, func(tx *Service) error {
+//		if err := tx.UserService.Create(user); err != nil {
+//			return err
+//		}
+//		return tx.AccountService.Create(&models.Account{UserID: user.ID})
+//	}, &TxOptions{Attempts: 3})
+func (s *Service) Transaction(ctx context.Context, fn func(tx *Service) error, opts ...*TxOptions) error {
+	return repo.Transaction(ctx, s.DB, func(tx *gorm.DB) error {
+		return fn(newService(tx, s.Hooks))
+	}, opts...)
+}
+
 // NewService returns a Service that embeds all generated model services.
+{{- if .Tenant }}
+// The queries of db on models with the {{.Tenant}} column are scoped to the tenant of their
+// context, see repo.UseTenancy, repo.WithTenant and repo.WithoutTenant. It fails if db
+// is already scoped with another column.
+func NewService(db *gorm.DB) (*Service, error) {
+	if err := repo.UseTenancy(db, "{{.Tenant}}"); err != nil {
+		return nil, err
+	}
+	return newService(db, &Hooks{}), nil
+}
+{{- else }}
 func NewService(db *gorm.DB) *Service {
+	return newService(db, &Hooks{})
+}
+{{- end }}
+
+// newService returns a Service running queries with db and hooks.
+func newService(db *gorm.DB, hooks *Hooks) *Service {
 	svc := &Service{
-		{{- range .}}
-		{{.}}Service: new{{.}}Service(db),
+		{{- range .Services}}
+		{{.}}Service: new{{.}}Service(db, hooks),
 		{{- end}}
-		DB: db,
+		DB:    db,
+		Hooks: hooks,
 	}
+
+	svc.Registry = make(map[string]any, {{len .Services}})
+	{{- range .Services}}
+	svc.Registry["{{.}}"] = svc.{{.}}Service
+	{{- end}}
 	return svc
 }
+
+// ParseQuery converts URL query parameters into options for the model named model e.g "User".
+// See the Parse<Model>Query functions.
+func ParseQuery(model string, query url.Values) (*Options, error) {
+	switch model {
+	{{- range .Services}}
+	case "{{.}}":
+		return Parse{{.}}Query(query)
+	{{- end}}
+	}
+	return nil, fmt.Errorf("unknown model %q", model)
+}
 `
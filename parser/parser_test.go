@@ -0,0 +1,484 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"gorm.io/gorm/schema"
+)
+
+const testModelsPkg = "github.com/abiiranathan/apigen/parser/testdata/models"
+
+func parseTestModels(t *testing.T) map[string]StructMeta {
+	t.Helper()
+	return Map(parseTestdata(t))
+}
+
+// parseTestdata parses the test models, failing on error diagnostics.
+func parseTestdata(t *testing.T) []StructMeta {
+	t.Helper()
+	structs, diagnostics, err := Parse([]string{"./testdata/models"}, nil)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if HasErrors(diagnostics) {
+		t.Fatalf("Parse returned error diagnostics: %v", diagnostics)
+	}
+	return structs
+}
+
+func findField(t *testing.T, st StructMeta, name string) Field {
+	t.Helper()
+	for _, f := range st.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	t.Fatalf("field %s.%s not found", st.Name, name)
+	return Field{}
+}
+
+func TestParseResolvesTypes(t *testing.T) {
+	structs := parseTestModels(t)
+
+	patient, ok := structs["Patient"]
+	if !ok {
+		t.Fatalf("expected Patient to be parsed")
+	}
+	if patient.Package != testModelsPkg {
+		t.Fatalf("expected package %q, got %q", testModelsPkg, patient.Package)
+	}
+	if patient.PKType != "models.PatientID" {
+		t.Fatalf("expected qualified PKType models.PatientID, got %q", patient.PKType)
+	}
+
+	tests := []struct {
+		field      string
+		typ        string
+		baseType   string
+		kind       Kind
+		underlying string
+		pkgPath    string
+		elemType   string
+		elemKind   Kind
+		nullable   bool
+	}{
+		{field: "ID", typ: "PatientID", baseType: "PatientID", kind: KindInt, underlying: "int64", pkgPath: testModelsPkg},
+		{field: "Name", typ: "string", baseType: "string", kind: KindString, underlying: "string"},
+		{field: "Sex", typ: "Sex", baseType: "Sex", kind: KindString, underlying: "string", pkgPath: testModelsPkg},
+		{field: "Nickname", typ: "*string", baseType: "string", kind: KindString, underlying: "string", nullable: true},
+		{field: "Code", typ: "Code", baseType: "string", kind: KindString, underlying: "string"},
+		{field: "Balance", typ: "Money", baseType: "Money", kind: KindFloat, underlying: "float64", pkgPath: testModelsPkg},
+		{field: "BirthDate", typ: "time.Time", baseType: "time.Time", kind: KindStruct, pkgPath: "time"},
+		{field: "Phone", typ: "sql.NullString", baseType: "sql.NullString", kind: KindStruct, pkgPath: "database/sql", nullable: true},
+		{field: "DeletedAt", typ: "gorm.DeletedAt", baseType: "gorm.DeletedAt", kind: KindStruct, pkgPath: "gorm.io/gorm", nullable: true},
+		{field: "Visits", typ: "[]*Visit", baseType: "Visit", kind: KindSlice, pkgPath: testModelsPkg, elemType: "Visit", elemKind: KindStruct},
+		{field: "Aliases", typ: "[]string", baseType: "string", kind: KindSlice, elemType: "string", elemKind: KindString},
+		{field: "Photo", typ: "[]byte", baseType: "byte", kind: KindSlice, elemType: "byte", elemKind: KindUint},
+		{field: "Meta", typ: "JSON[string]", baseType: "JSON[string]", kind: KindStruct, pkgPath: testModelsPkg},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			f := findField(t, patient, tt.field)
+			if f.Type != tt.typ {
+				t.Errorf("Type = %q, want %q", f.Type, tt.typ)
+			}
+			if f.BaseType != tt.baseType {
+				t.Errorf("BaseType = %q, want %q", f.BaseType, tt.baseType)
+			}
+			if f.Kind != tt.kind {
+				t.Errorf("Kind = %q, want %q", f.Kind, tt.kind)
+			}
+			if f.Underlying != tt.underlying {
+				t.Errorf("Underlying = %q, want %q", f.Underlying, tt.underlying)
+			}
+			if f.PkgPath != tt.pkgPath {
+				t.Errorf("PkgPath = %q, want %q", f.PkgPath, tt.pkgPath)
+			}
+			if f.ElemType != tt.elemType {
+				t.Errorf("ElemType = %q, want %q", f.ElemType, tt.elemType)
+			}
+			if f.ElemKind != tt.elemKind {
+				t.Errorf("ElemKind = %q, want %q", f.ElemKind, tt.elemKind)
+			}
+			if f.Nullable != tt.nullable {
+				t.Errorf("Nullable = %v, want %v", f.Nullable, tt.nullable)
+			}
+		})
+	}
+
+	if f := findField(t, patient, "BirthDate"); !f.TextMarshaler {
+		t.Errorf("expected time.Time to be detected as a TextMarshaler")
+	}
+	if f := findField(t, patient, "Sex"); f.QualifiedType != "models.Sex" {
+		t.Errorf("expected qualified type models.Sex, got %q", f.QualifiedType)
+	}
+}
+
+func TestParseSkipsUnsupportedFieldsAndTypes(t *testing.T) {
+	structs := parseTestModels(t)
+
+	for _, f := range structs["Patient"].Fields {
+		if f.Name == "Attrs" || f.Name == "secret" {
+			t.Errorf("expected field %s to be ignored", f.Name)
+		}
+	}
+
+	if _, ok := structs["JSON"]; ok {
+		t.Errorf("expected generic struct JSON to be ignored")
+	}
+	if _, ok := structs["Sex"]; ok {
+		t.Errorf("expected non-struct type Sex to be ignored")
+	}
+
+	audit, ok := structs["Audit"]
+	if !ok || !audit.Skip {
+		t.Errorf("expected Audit to be parsed with Skip set from its doc comment")
+	}
+}
+
+func TestParseFlattensEmbeddedStructs(t *testing.T) {
+	structs := parseTestModels(t)
+
+	invoice := structs["Invoice"]
+	if invoice.PKType != "uint" {
+		t.Fatalf("expected ID promoted from gorm.Model as uint primary key, got %q", invoice.PKType)
+	}
+
+	want := []struct {
+		name, column, path string
+		embedded           bool
+	}{
+		{name: "ID", column: "id"},
+		{name: "CreatedAt", column: "created_at"},
+		{name: "UpdatedAt", column: "updated_at"},
+		{name: "DeletedAt", column: "deleted_at"},
+		{name: "Number", column: "number"},
+		{name: "Owner", embedded: true},
+		{name: "Name", column: "owner_name", path: "Owner"},
+		{name: "Email", column: "owner_mail", path: "Owner"},
+	}
+
+	if len(invoice.Fields) != len(want) {
+		t.Fatalf("expected %d fields, got %d: %+v", len(want), len(invoice.Fields), invoice.Fields)
+	}
+
+	for i, w := range want {
+		f := invoice.Fields[i]
+		if f.Name != w.name || f.Column != w.column || f.EmbeddedPath != w.path || f.Embedded != w.embedded {
+			t.Errorf("field %d = {%s %s %s %v}, want %+v", i, f.Name, f.Column, f.EmbeddedPath, f.Embedded, w)
+		}
+	}
+
+	doctor := structs["Doctor"]
+	if doctor.PKType != "uint" {
+		t.Errorf("expected ID promoted from *BaseModel as uint primary key, got %q", doctor.PKType)
+	}
+	if f := findField(t, doctor, "CreatedAt"); f.Parent != "Doctor" || f.Type != "time.Time" {
+		t.Errorf("expected promoted CreatedAt to belong to Doctor, got %+v", f)
+	}
+}
+
+func TestParseDecodesGormTags(t *testing.T) {
+	structs := parseTestModels(t)
+	patient := structs["Patient"]
+
+	visits := findField(t, patient, "Visits")
+	if visits.Gorm.ForeignKey != "PatientID" || !visits.Preload {
+		t.Errorf("expected Visits to be a preloaded relation with foreignKey PatientID, got %+v", visits.Gorm)
+	}
+
+	if settings := findField(t, patient, "Settings"); settings.Kind != KindMap || settings.Gorm.Serializer != "json" {
+		t.Errorf("expected map field with a serializer to be kept, got %+v", settings)
+	}
+
+	if age := findField(t, patient, "Age"); !age.Gorm.Ignore || age.Preload {
+		t.Errorf("expected Age to be ignored by GORM, got %+v", age.Gorm)
+	}
+
+	if email := findField(t, structs["Contact"], "Email"); email.Column != "mail" {
+		t.Errorf("expected column tag to set the column name, got %q", email.Column)
+	}
+}
+
+func TestParsePrimaryKeys(t *testing.T) {
+	structs := parseTestModels(t)
+
+	tests := []struct {
+		model  string
+		pkType string
+		keys   []string
+	}{
+		{model: "Patient", pkType: "models.PatientID", keys: []string{"ID"}},
+		{model: "Invoice", pkType: "uint", keys: []string{"ID"}},
+		{model: "Country", pkType: "string", keys: []string{"Code"}},
+		{model: "Tag", pkType: "string", keys: []string{"ID"}},
+		{model: "Device", pkType: "ids.UUID", keys: []string{"ID"}},
+		{model: "Enrollment", pkType: "", keys: []string{"PatientID", "VisitID"}},
+		{model: "Contact", pkType: "", keys: nil},
+	}
+
+	for _, tt := range tests {
+		st := structs[tt.model]
+		if st.PKType != tt.pkType {
+			t.Errorf("%s: expected PKType %q, got %q", tt.model, tt.pkType, st.PKType)
+		}
+
+		var keys []string
+		for _, f := range st.PrimaryKeyFields() {
+			keys = append(keys, f.Name)
+		}
+		if strings.Join(keys, ",") != strings.Join(tt.keys, ",") {
+			t.Errorf("%s: expected primary key fields %v, got %v", tt.model, tt.keys, keys)
+		}
+	}
+}
+
+func TestParseInfersRelations(t *testing.T) {
+	structs := parseTestModels(t)
+
+	account := structs["Account"]
+	want := []Relation{
+		{Field: "Role", Kind: RelationBelongsTo, Model: "Role", Package: testModelsPkg, ForeignKey: "RoleID", References: "ID"},
+		{Field: "Profile", Kind: RelationHasOne, Model: "Profile", Package: testModelsPkg, ForeignKey: "AccountID", References: "ID"},
+		{Field: "Posts", Kind: RelationHasMany, Model: "Post", Package: testModelsPkg, ForeignKey: "AccountID", References: "ID"},
+		{
+			Field: "Groups", Kind: RelationMany2Many, Model: "Group", Package: testModelsPkg, ForeignKey: "ID", References: "ID",
+			JoinTable: "account_groups", JoinForeignKey: "AccountID", JoinReferences: "GroupID",
+		},
+		{
+			Field: "Photos", Kind: RelationHasMany, Model: "Photo", Package: testModelsPkg, ForeignKey: "OwnerID", References: "ID",
+			PolymorphicType: "OwnerType", PolymorphicValue: "accounts",
+		},
+		{
+			Field: "Avatar", Kind: RelationHasOne, Model: "Photo", Package: testModelsPkg, ForeignKey: "OwnerID", References: "ID",
+			PolymorphicType: "OwnerType", PolymorphicValue: "accounts",
+		},
+	}
+
+	if len(account.Relations) != len(want) {
+		t.Fatalf("expected %d relations, got %d: %+v", len(want), len(account.Relations), account.Relations)
+	}
+	for i, r := range account.Relations {
+		if r != want[i] {
+			t.Errorf("relation %d:\nexpected %+v\ngot      %+v", i, want[i], r)
+		}
+		if !findField(t, account, r.Field).Preload {
+			t.Errorf("expected relation field %s to be preloaded", r.Field)
+		}
+	}
+
+	if findField(t, account, "RoleID").Preload {
+		t.Errorf("expected foreign key RoleID not to be a relation")
+	}
+
+	// Tagged relations on both sides of a has-many.
+	if r, ok := structs["Patient"].Relation("Visits"); !ok || r.Kind != RelationHasMany || r.ForeignKey != "PatientID" {
+		t.Errorf("expected Patient.Visits to be a has-many through PatientID, got %+v", r)
+	}
+	if r, ok := structs["Visit"].Relation("Patient"); !ok || r.Kind != RelationBelongsTo || r.ForeignKey != "PatientID" {
+		t.Errorf("expected Visit.Patient to be a belongs-to through PatientID, got %+v", r)
+	}
+
+	// Struct columns are not relations.
+	if _, ok := structs["Patient"].Relation("BirthDate"); ok {
+		t.Errorf("expected time.Time field not to be a relation")
+	}
+}
+
+func TestParseDiagnostics(t *testing.T) {
+	_, diagnostics, err := Parse([]string{"./testdata/models"}, nil)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	var attrs *Diagnostic
+	for i, d := range diagnostics {
+		if d.Struct == "Patient" && d.Field == "Attrs" {
+			attrs = &diagnostics[i]
+		}
+		if d.Field == "Settings" {
+			t.Errorf("expected no diagnostic for serialized map field, got %s", d)
+		}
+	}
+	if attrs == nil {
+		t.Fatalf("expected a diagnostic for the ignored map field Patient.Attrs, got %v", diagnostics)
+	}
+	if attrs.Severity != SeverityWarning || !strings.HasSuffix(attrs.Pos.Filename, "models.go") || attrs.Pos.Line == 0 {
+		t.Errorf("expected a warning positioned in models.go, got %s", attrs)
+	}
+	if !strings.Contains(attrs.String(), "warning: Patient.Attrs: unsupported type map[string]any") {
+		t.Errorf("unexpected diagnostic message %q", attrs)
+	}
+
+	_, diagnostics, err = Parse([]string{"./testdata/badtags"}, nil)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if len(diagnostics) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %v", diagnostics)
+	}
+	if d := diagnostics[0]; d.Field != "Name" || d.Severity != SeverityError {
+		t.Errorf("expected an error for the missing column name, got %s", d)
+	}
+	if d := diagnostics[1]; d.Field != "Email" || d.Severity != SeverityWarning {
+		t.Errorf("expected a warning for the unknown setting, got %s", d)
+	}
+	if !HasErrors(diagnostics) {
+		t.Errorf("expected HasErrors to report the error diagnostic")
+	}
+}
+
+func TestParsePackageErrors(t *testing.T) {
+	structs, diagnostics, err := Parse([]string{"./testdata/broken"}, nil)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if len(structs) != 0 {
+		t.Errorf("expected no structs from a package that does not compile, got %d", len(structs))
+	}
+	if !HasErrors(diagnostics) {
+		t.Fatalf("expected error diagnostics, got %v", diagnostics)
+	}
+
+	d := diagnostics[0]
+	if !strings.HasSuffix(d.Pos.Filename, "broken.go") || d.Pos.Line != 6 || d.Pos.Column == 0 {
+		t.Errorf("expected the error to be positioned at broken.go:6, got %s", d)
+	}
+	if !strings.Contains(d.Message, "UnknownType") {
+		t.Errorf("expected the type error in the message, got %q", d.Message)
+	}
+}
+
+func TestParseTableNames(t *testing.T) {
+	structs := parseTestModels(t)
+
+	tests := map[string]string{
+		"Category":   "categories",
+		"LegacyUser": "tbl_users",
+		"Archive":    "archives",
+		"Patient":    "patients",
+	}
+	for model, table := range tests {
+		if got := structs[model].Table; got != table {
+			t.Errorf("%s: expected table %q, got %q", model, table, got)
+		}
+	}
+
+	if col := findField(t, structs["LegacyUser"], "Email").Column; col != "email_address" {
+		t.Errorf("expected column tag to be honored, got %q", col)
+	}
+
+	_, diagnostics, _ := Parse([]string{"./testdata/models"}, nil)
+	found := false
+	for _, d := range diagnostics {
+		if d.Struct == "Archive" && d.Severity == SeverityWarning && strings.Contains(d.Message, "TableName") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected a warning for the non-constant TableName of Archive, got %v", diagnostics)
+	}
+}
+
+func TestParseNamingStrategy(t *testing.T) {
+	namer := schema.NamingStrategy{TablePrefix: "app_", SingularTable: true}
+	metadata, _, err := Parse([]string{"./testdata/models"}, namer)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	structs := Map(metadata)
+
+	tests := map[string]string{
+		"Category":   "app_category",
+		"LegacyUser": "tbl_users", // TableName methods are used as is
+	}
+	for model, table := range tests {
+		if got := structs[model].Table; got != table {
+			t.Errorf("%s: expected table %q, got %q", model, table, got)
+		}
+	}
+
+	if r, _ := structs["Account"].Relation("Groups"); r.JoinTable != "app_account_groups" {
+		t.Errorf("expected join table with prefix, got %q", r.JoinTable)
+	}
+	if r, _ := structs["Account"].Relation("Photos"); r.PolymorphicValue != "accounts" {
+		t.Errorf("expected explicit polymorphic value to be kept, got %q", r.PolymorphicValue)
+	}
+	if r, _ := structs["Account"].Relation("Avatar"); r.PolymorphicValue != "app_account" {
+		t.Errorf("expected polymorphic value to default to the owner table, got %q", r.PolymorphicValue)
+	}
+}
+
+func TestParseDirectives(t *testing.T) {
+	structs := parseTestModels(t)
+
+	member := structs["Member"]
+	if member.Table != "club_members" || !member.TableOverride {
+		t.Errorf("expected apigen:table to set the table, got %q", member.Table)
+	}
+	if len(member.Preloads) != 1 || member.Preloads[0] != "Role" {
+		t.Errorf("expected apigen:preload to set Preloads to [Role], got %v", member.Preloads)
+	}
+	if member.ReadOnly || member.NoPreload {
+		t.Errorf("expected Member not to be read-only nor without preloads")
+	}
+	if !findField(t, member, "Password").Hidden {
+		t.Errorf("expected apigen:hidden line comment to hide Password")
+	}
+	if findField(t, member, "Email").Hidden {
+		t.Errorf("expected Email not to be hidden")
+	}
+
+	if f, ok := structs["Document"].VersionField(); !ok || f.Name != "Revision" || !f.Version {
+		t.Errorf("expected apigen:version to set the version field, got %+v", f)
+	}
+	if f, ok := structs["Note"].VersionField(); !ok || f.Column != "version" {
+		t.Errorf("expected the Version field to be the version field, got %+v", f)
+	}
+	if _, ok := member.VersionField(); ok {
+		t.Errorf("expected Member to have no version field")
+	}
+	if col := findField(t, member, "Email").Column; col != "email" {
+		t.Errorf("expected columns to be named from the overridden table, got %q", col)
+	}
+
+	stats := structs["MemberStats"]
+	if !stats.ReadOnly || !stats.NoPreload {
+		t.Errorf("expected apigen:readonly and apigen:nopreload to be set, got %+v", stats)
+	}
+	if !structs["Audit"].Skip {
+		t.Errorf("expected apigen:skip to be set")
+	}
+
+	_, diagnostics, err := Parse([]string{"./testdata/baddirectives"}, nil)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	want := []struct {
+		severity Severity
+		field    string
+		message  string
+	}{
+		{SeverityWarning, "Email", "apigen:readonly does not apply to fields"},
+		{SeverityError, "Name", "apigen:version requires an integer field, got string"},
+		{SeverityError, "", "apigen:table requires a table name"},
+		{SeverityError, "", `apigen:preload references unknown field "Team"`},
+		{SeverityWarning, "", "apigen:readonly does not take a value"},
+		{SeverityWarning, "", "unknown directive apigen:cache"},
+	}
+	if len(diagnostics) != len(want) {
+		t.Fatalf("expected %d diagnostics, got %v", len(want), diagnostics)
+	}
+	for i, w := range want {
+		d := diagnostics[i]
+		if d.Severity != w.severity || d.Field != w.field || !strings.Contains(d.Message, w.message) {
+			t.Errorf("diagnostic %d: expected %s %q on field %q, got %s", i, w.severity, w.message, w.field, d)
+		}
+		if d.Pos.Line == 0 {
+			t.Errorf("diagnostic %d: expected a position, got %s", i, d)
+		}
+	}
+}
@@ -59,11 +59,18 @@ func writeJSONToFile(filename string, data map[string][]string) error {
 }
 
 // GetPreloadMap returns a map of struct names to their respective preload fields, including nested relationships.
+// Models with apigen:preload or apigen:nopreload directives preload the given relations only.
 func GetPreloadMap(structs []StructMeta, cfg *config.Config) map[string][]string {
 	preloads := make(map[string][]string)
 	preloadDepth := cfg.PreloadDepth
 
 	for _, st := range structs {
+		// Directives replace the relations found in the model.
+		if st.NoPreload || len(st.Preloads) > 0 {
+			preloads[st.Name] = slices.Clone(st.Preloads)
+			continue
+		}
+
 		preloadFields := getPreloadFieldsRecursive(st, structs, "", 0, int(preloadDepth))
 		if _, ok := preloads[st.Name]; ok {
 			// Merge existing preloads with new ones
@@ -95,7 +102,7 @@ func getPreloadFieldsRecursive(st StructMeta, allStructs []StructMeta, prefix st
 			preloadFields = append(preloadFields, field.Name)
 
 			for _, nestedStruct := range allStructs {
-				if nestedStruct.Name == field.BaseType {
+				if isRelationTarget(st, field, nestedStruct) {
 					if !strings.Contains(prefix, fmt.Sprintf(".%s.", field.Name)) {
 						// Build the new prefix for the recursive call
 						newPrefix := fmt.Sprintf("%s.%s.", prefix, field.Name)
@@ -118,3 +125,11 @@ func getPreloadFieldsRecursive(st StructMeta, allStructs []StructMeta, prefix st
 
 	return preloadFields
 }
+
+// isRelationTarget reports whether target is the model referenced by relation field f of st.
+func isRelationTarget(st StructMeta, f Field, target StructMeta) bool {
+	if r, ok := st.Relation(f.Name); ok {
+		return target.Name == r.Model && target.Package == r.Package
+	}
+	return target.Name == f.BaseType
+}
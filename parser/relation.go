@@ -0,0 +1,223 @@
+package parser
+
+import (
+	"strings"
+
+	"gorm.io/gorm/schema"
+)
+
+// RelationKind classifies an association between two models.
+type RelationKind string
+
+const (
+	RelationBelongsTo RelationKind = "belongs_to" // Foreign key on the owner e.g User.RoleID -> Role
+	RelationHasOne    RelationKind = "has_one"    // Foreign key on the target e.g User -> Profile.UserID
+	RelationHasMany   RelationKind = "has_many"   // Foreign key on the targets e.g User -> []Post.UserID
+	RelationMany2Many RelationKind = "many2many"  // Join table holding both keys e.g user_tags
+)
+
+// Relation is an association declared by a field of a model.
+// Keys are Go field names like in gorm tags, not column names.
+type Relation struct {
+	Field   string       // Field declaring the relation e.g "Role"
+	Kind    RelationKind // Kind of association
+	Model   string       // Target model name e.g "Role"
+	Package string       // Import path of the package declaring the target model
+
+	// ForeignKey is the field holding the foreign key. It is declared on the owner
+	// for belongs-to e.g "RoleID", on the target for has-one and has-many e.g "UserID"
+	// and on the join table for many2many.
+	ForeignKey string
+
+	// References is the field referenced by the foreign key. It is declared on the target
+	// for belongs-to and on the owner otherwise. Usually the primary key e.g "ID".
+	References string
+
+	JoinTable      string // many2many join table e.g "user_tags"
+	JoinForeignKey string // many2many join table field referencing the owner e.g "UserID"
+	JoinReferences string // many2many join table field referencing the target e.g "TagID"
+
+	PolymorphicType  string // Field on the target storing the owner type e.g "OwnerType". Empty if not polymorphic
+	PolymorphicValue string // Value stored in PolymorphicType e.g "users"
+}
+
+// IsPolymorphic reports whether the has-one or has-many relation is polymorphic.
+func (r Relation) IsPolymorphic() bool {
+	return r.PolymorphicType != ""
+}
+
+// IsCollection reports whether the relation holds many targets.
+func (r Relation) IsCollection() bool {
+	return r.Kind == RelationHasMany || r.Kind == RelationMany2Many
+}
+
+// Relation returns the relation declared by field name.
+func (s StructMeta) Relation(name string) (Relation, bool) {
+	for _, r := range s.Relations {
+		if r.Field == name {
+			return r, true
+		}
+	}
+	return Relation{}, false
+}
+
+// modelKey identifies a model across packages.
+type modelKey struct {
+	pkg, name string
+}
+
+// inferRelations classifies the association fields of structs like GORM does, from
+// their tags or from naming conventions e.g "Role Role" next to "RoleID" is a belongs-to.
+// Fields found to be relations are marked with Field.Preload.
+func inferRelations(structs []StructMeta, namer schema.Namer) {
+	models := make(map[modelKey]*StructMeta, len(structs))
+	for i := range structs {
+		models[modelKey{structs[i].Package, structs[i].Name}] = &structs[i]
+	}
+
+	for i := range structs {
+		owner := &structs[i]
+		for j := range owner.Fields {
+			f := &owner.Fields[j]
+			if !isRelationCandidate(*f) {
+				continue
+			}
+
+			target := models[modelKey{f.PkgPath, typeName(f.BaseType)}]
+			relation, ok := inferRelation(owner, *f, target, namer)
+			if !ok {
+				continue
+			}
+
+			f.Preload = true
+			owner.Relations = append(owner.Relations, relation)
+		}
+	}
+}
+
+// isRelationCandidate reports whether f may reference other models: a struct
+// or a slice of structs stored without a serializer.
+func isRelationCandidate(f Field) bool {
+	if f.Embedded || f.EmbeddedPath != "" || f.Gorm.Ignore || f.Gorm.Serializer != "" {
+		return false
+	}
+
+	kind := f.Kind
+	if kind == KindSlice || kind == KindArray {
+		kind = f.ElemKind
+	}
+	return kind == KindStruct && f.PkgPath != "" && !f.TextMarshaler && !isKnownValueType(f)
+}
+
+// isKnownValueType reports whether f is a struct type stored in a single column.
+func isKnownValueType(f Field) bool {
+	switch f.PkgPath {
+	case "time", "database/sql", "gorm.io/gorm", "gorm.io/datatypes", "github.com/google/uuid":
+		return true
+	}
+	return false
+}
+
+// inferRelation classifies relation field f of owner. target is nil for models
+// outside the parsed packages, only relations declared with tags are recognized then.
+// namer resolves the join table names of many2many relations.
+func inferRelation(owner *StructMeta, f Field, target *StructMeta, namer schema.Namer) (Relation, bool) {
+	tag := f.Gorm
+	r := Relation{
+		Field:   f.Name,
+		Model:   typeName(f.BaseType),
+		Package: f.PkgPath,
+	}
+	collection := f.Kind == KindSlice || f.Kind == KindArray
+
+	ownerPK := primaryKeyName(owner)
+
+	switch {
+	case tag.Many2Many != "":
+		r.Kind = RelationMany2Many
+		r.JoinTable = namer.JoinTableName(tag.Many2Many)
+		r.ForeignKey = firstNonEmpty(tag.ForeignKey, ownerPK)
+		r.References = firstNonEmpty(tag.References, primaryKeyName(target))
+		r.JoinForeignKey = firstNonEmpty(tag.JoinForeignKey, owner.Name+r.ForeignKey)
+		r.JoinReferences = firstNonEmpty(tag.JoinReferences, r.Model+r.References)
+
+		// Self-referencing join tables can't use the same column twice.
+		if r.JoinForeignKey == r.JoinReferences {
+			r.JoinReferences = "Ref" + r.JoinReferences
+		}
+		return r, true
+
+	case tag.Polymorphic != "" || tag.PolymorphicType != "":
+		r.Kind = RelationHasOne
+		if collection {
+			r.Kind = RelationHasMany
+		}
+		r.PolymorphicType = firstNonEmpty(tag.PolymorphicType, tag.Polymorphic+"Type")
+		r.ForeignKey = firstNonEmpty(tag.PolymorphicID, tag.Polymorphic+"ID")
+		r.References = firstNonEmpty(tag.References, ownerPK)
+		r.PolymorphicValue = firstNonEmpty(tag.PolymorphicValue, owner.Table)
+		return r, true
+
+	case collection:
+		r.Kind = RelationHasMany
+		r.ForeignKey = firstNonEmpty(tag.ForeignKey, owner.Name+ownerPK)
+		r.References = firstNonEmpty(tag.References, ownerPK)
+		return r, tag.ForeignKey != "" || hasField(target, r.ForeignKey)
+	}
+
+	// A single struct is a belongs-to when the owner holds the foreign key
+	// e.g "Role Role" + "RoleID", otherwise a has-one when the target holds it.
+	belongsTo := Relation{
+		Field:      r.Field,
+		Kind:       RelationBelongsTo,
+		Model:      r.Model,
+		Package:    r.Package,
+		ForeignKey: firstNonEmpty(tag.ForeignKey, f.Name+primaryKeyName(target)),
+		References: firstNonEmpty(tag.References, primaryKeyName(target)),
+	}
+	if hasField(owner, belongsTo.ForeignKey) {
+		return belongsTo, true
+	}
+
+	r.Kind = RelationHasOne
+	r.ForeignKey = firstNonEmpty(tag.ForeignKey, owner.Name+ownerPK)
+	r.References = firstNonEmpty(tag.References, ownerPK)
+	return r, tag.ForeignKey != "" || hasField(target, r.ForeignKey)
+}
+
+// primaryKeyName returns the name of the primary key field of st, "ID" if unknown.
+func primaryKeyName(st *StructMeta) string {
+	if st != nil {
+		if pks := st.PrimaryKeyFields(); len(pks) == 1 {
+			return pks[0].Name
+		}
+	}
+	return "ID"
+}
+
+// hasField reports whether st has a column field called name.
+func hasField(st *StructMeta, name string) bool {
+	if st == nil {
+		return false
+	}
+	for _, f := range st.Fields {
+		if f.Name == name && f.EmbeddedPath == "" && !f.Embedded {
+			return true
+		}
+	}
+	return false
+}
+
+// typeName returns the type name of a type string without its package qualifier.
+func typeName(typ string) string {
+	return typ[strings.LastIndexByte(typ, '.')+1:]
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+)
+
+// tableName returns the table of model named like GORM does: the string returned by
+// its TableName method if any, otherwise the name given by the naming strategy.
+// TableName methods must return a constant, other results are reported at pos.
+func (p *modelParser) tableName(named *types.Named, pos token.Pos) string {
+	name := named.Obj().Name()
+
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(named), true, named.Obj().Pkg(), "TableName")
+	fn, ok := obj.(*types.Func)
+	if !ok || !isTablerSignature(fn) {
+		return p.namer.TableName(name)
+	}
+
+	if table, ok := p.constantResult(fn); ok {
+		return table
+	}
+
+	table := p.namer.TableName(name)
+	p.report(pos, SeverityWarning, name, "", "TableName does not return a constant string, assuming table %q", table)
+	return table
+}
+
+// isTablerSignature reports whether fn implements gorm's schema.Tabler i.e TableName() string.
+func isTablerSignature(fn *types.Func) bool {
+	sig := fn.Type().(*types.Signature)
+	if sig.Params().Len() != 0 || sig.Results().Len() != 1 {
+		return false
+	}
+	return types.Identical(sig.Results().At(0).Type().Underlying(), types.Typ[types.String])
+}
+
+// constantResult returns the string returned by fn if its body is a single return
+// of a constant expression e.g return "app_users".
+func (p *modelParser) constantResult(fn *types.Func) (string, bool) {
+	for _, file := range p.pkg.Syntax {
+		for _, decl := range file.Decls {
+			funcDecl, ok := decl.(*ast.FuncDecl)
+			if !ok || p.pkg.TypesInfo.Defs[funcDecl.Name] != fn {
+				continue
+			}
+
+			if funcDecl.Body == nil || len(funcDecl.Body.List) != 1 {
+				return "", false
+			}
+
+			ret, ok := funcDecl.Body.List[0].(*ast.ReturnStmt)
+			if !ok || len(ret.Results) != 1 {
+				return "", false
+			}
+
+			value := p.pkg.TypesInfo.Types[ret.Results[0]].Value
+			if value == nil || value.Kind() != constant.String {
+				return "", false
+			}
+			return constant.StringVal(value), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,14 @@
+// Package baddirectives has models with invalid apigen directives.
+package baddirectives
+
+// User has invalid directives.
+//
+// apigen:table
+// apigen:preload=Team
+// apigen:readonly=true
+// apigen:cache
+type User struct {
+	ID    int
+	Email string // apigen:hidden apigen:readonly
+	Name  string // apigen:version
+}
@@ -0,0 +1,8 @@
+// Package badtags has models with invalid gorm tags.
+package badtags
+
+type User struct {
+	ID    int
+	Name  string `gorm:"column"`
+	Email string `gorm:"uniqe"`
+}
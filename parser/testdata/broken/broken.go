@@ -0,0 +1,7 @@
+// Package broken does not compile.
+package broken
+
+type User struct {
+	ID   int
+	Role UnknownType
+}
@@ -0,0 +1,18 @@
+// Package ids declares identifier types used by the test models.
+package ids
+
+import "encoding/hex"
+
+// UUID is a 128 bit identifier encoded as hex text like uuid.UUID.
+type UUID [16]byte
+
+// MarshalText implements encoding.TextMarshaler.
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(u[:])), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (u *UUID) UnmarshalText(text []byte) error {
+	_, err := hex.Decode(u[:], text)
+	return err
+}
@@ -0,0 +1,251 @@
+// Package models contains models used to test the parser.
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/abiiranathan/apigen/parser/testdata/ids"
+	"gorm.io/gorm"
+)
+
+type Sex string
+
+// Code is an alias and resolves to string.
+type Code = string
+
+type Money float64
+
+type PatientID int64
+
+// JSON is generic and is not parsed as a model.
+type JSON[T any] struct {
+	Data T
+}
+
+type Patient struct {
+	ID        PatientID      `json:"id"`
+	Name      string         `json:"name"`
+	Sex       Sex            `json:"sex"`
+	Nickname  *string        `json:"nickname"`
+	Code      Code           `json:"code"`
+	Balance   Money          `json:"balance"`
+	BirthDate time.Time      `json:"birth_date"`
+	Phone     sql.NullString `json:"phone"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at"`
+	Visits    []*Visit       `json:"visits" gorm:"foreignKey:PatientID"`
+	Aliases   []string       `json:"aliases" gorm:"serializer:json"`
+	Photo     []byte         `json:"photo"`
+	Meta      JSON[string]   `json:"meta" gorm:"serializer:json"`
+	Attrs     map[string]any `json:"attrs"`
+	Settings  map[string]any `json:"settings" gorm:"serializer:json"`
+	Age       int            `json:"age" gorm:"-"`
+	secret    string
+}
+
+// Visit belongs to a Patient.
+type Visit struct {
+	ID        int       `json:"id"`
+	PatientID PatientID `json:"patient_id"`
+	Patient   *Patient  `json:"patient" gorm:"foreignKey:PatientID"`
+}
+
+// Audit is skipped by the generators.
+//
+// apigen:skip
+type Audit struct {
+	ID uint
+}
+
+// BaseModel is embedded by models sharing the same primary key and timestamps.
+type BaseModel struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+}
+
+// Contact is embedded with a column prefix.
+type Contact struct {
+	Name  string
+	Email string `gorm:"column:mail"`
+}
+
+type Invoice struct {
+	gorm.Model
+	Number string
+	Owner  Contact `gorm:"embedded;embeddedPrefix:owner_"`
+}
+
+type Doctor struct {
+	*BaseModel
+	Name string
+}
+
+// Country uses a natural string primary key.
+type Country struct {
+	Code string `gorm:"primaryKey;size:2"`
+	Name string
+}
+
+// Enrollment is a join table with a composite primary key.
+type Enrollment struct {
+	PatientID PatientID `gorm:"primaryKey"`
+	VisitID   int       `gorm:"primaryKey"`
+	Notes     string
+}
+
+// Device uses a primary key declared in another package.
+type Device struct {
+	ID   ids.UUID `json:"id"`
+	Name string   `json:"name"`
+}
+
+// Tag uses a string ID primary key.
+type Tag struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+type Role struct {
+	ID   uint
+	Name string
+}
+
+// Account declares its relations with GORM's naming conventions where possible.
+type Account struct {
+	ID      uint
+	RoleID  uint
+	Role    Role     // belongs-to through RoleID
+	Profile *Profile // has-one through Profile.AccountID
+	Posts   []Post   // has-many through Post.AccountID
+	Groups  []*Group `gorm:"many2many:account_groups"`
+	Photos  []Photo  `gorm:"polymorphic:Owner;polymorphicValue:accounts"`
+	Avatar  Photo    `gorm:"polymorphic:Owner"`
+}
+
+type Profile struct {
+	ID        uint
+	AccountID uint
+	Bio       string
+}
+
+type Post struct {
+	ID        uint
+	AccountID uint
+	Title     string
+}
+
+type Group struct {
+	ID   uint
+	Name string
+}
+
+type Photo struct {
+	ID        uint
+	OwnerID   uint
+	OwnerType string
+	URL       string
+}
+
+// Category is pluralized like GORM does: categories.
+type Category struct {
+	ID   uint
+	Name string
+}
+
+// LegacyUser overrides its table name and column names.
+type LegacyUser struct {
+	ID    uint
+	Email string `gorm:"column:email_address"`
+}
+
+func (LegacyUser) TableName() string {
+	return "tbl_users"
+}
+
+// Archive computes its table name at runtime.
+type Archive struct {
+	ID uint
+}
+
+func (a *Archive) TableName() string {
+	return "archive_" + time.Now().Format("2006")
+}
+
+// Member is configured with generation directives.
+//
+// apigen:table=club_members
+// apigen:preload=Role
+type Member struct {
+	ID       uint     `json:"id"`
+	RoleID   uint     `json:"role_id"`
+	Role     Role     `json:"role"`
+	Groups   []*Group `json:"groups" gorm:"many2many:member_groups"`
+	Email    string   `json:"email"`
+	Password string   `json:"password"` // apigen:hidden
+}
+
+// MemberStats is backed by a SQL view.
+//
+// apigen:readonly
+// apigen:nopreload
+type MemberStats struct {
+	MemberID uint   `json:"member_id" gorm:"primaryKey"`
+	Member   Member `json:"member"`
+	Visits   int    `json:"visits"`
+}
+
+// Status is a string enum.
+type Status string
+
+const (
+	StatusActive   Status = "active"
+	StatusInactive Status = "inactive"
+	StatusDefault         = StatusActive // Same value, listed once
+)
+
+// Priority is an integer enum.
+type Priority int
+
+const (
+	PriorityLow Priority = iota + 1
+	PriorityHigh
+)
+
+// Level is not an enum.
+//
+// apigen:skip
+type Level int
+
+const LevelDebug Level = 0
+
+// Color scans itself and is not an enum.
+type Color string
+
+const Red Color = "red"
+
+func (c *Color) Scan(src any) error {
+	*c = Color(src.(string))
+	return nil
+}
+
+// Subscription is upserted on its first unique index, a composite one.
+type Subscription struct {
+	ID        uint
+	AccountID uint   `gorm:"uniqueIndex:idx_account_plan,priority:2"`
+	Plan      string `gorm:"uniqueIndex:idx_account_plan,priority:1"`
+	Email     string `gorm:"uniqueIndex"`
+}
+
+// Document is updated with optimistic locking on its revision.
+type Document struct {
+	ID       uint
+	Title    string
+	Revision int64 // apigen:version
+}
+
+// Note is updated with optimistic locking on its Version field.
+type Note struct {
+	ID      uint
+	Body    string
+	Version uint
+}
@@ -0,0 +1,210 @@
+package parser
+
+import (
+	"go/types"
+	"strings"
+)
+
+// Kind is the underlying kind of a field type as resolved by go/types.
+type Kind string
+
+const (
+	KindInvalid   Kind = ""
+	KindBool      Kind = "bool"
+	KindInt       Kind = "int"
+	KindUint      Kind = "uint"
+	KindFloat     Kind = "float"
+	KindComplex   Kind = "complex"
+	KindString    Kind = "string"
+	KindStruct    Kind = "struct"
+	KindSlice     Kind = "slice"
+	KindArray     Kind = "array"
+	KindMap       Kind = "map"
+	KindInterface Kind = "interface"
+	KindFunc      Kind = "func"
+	KindChan      Kind = "chan"
+)
+
+// IsBasic reports whether k is a boolean, numeric or string kind.
+func (k Kind) IsBasic() bool {
+	switch k {
+	case KindBool, KindInt, KindUint, KindFloat, KindComplex, KindString:
+		return true
+	}
+	return false
+}
+
+// IsNumeric reports whether k is an integer or floating point kind.
+func (k Kind) IsNumeric() bool {
+	return k == KindInt || k == KindUint || k == KindFloat
+}
+
+// kindOf returns the Kind of the underlying type of typ.
+func kindOf(typ types.Type) Kind {
+	switch t := typ.Underlying().(type) {
+	case *types.Basic:
+		info := t.Info()
+		switch {
+		case info&types.IsBoolean != 0:
+			return KindBool
+		case info&types.IsUnsigned != 0:
+			return KindUint
+		case info&types.IsInteger != 0:
+			return KindInt
+		case info&types.IsFloat != 0:
+			return KindFloat
+		case info&types.IsComplex != 0:
+			return KindComplex
+		case info&types.IsString != 0:
+			return KindString
+		}
+	case *types.Struct:
+		return KindStruct
+	case *types.Slice:
+		return KindSlice
+	case *types.Array:
+		return KindArray
+	case *types.Map:
+		return KindMap
+	case *types.Interface:
+		return KindInterface
+	case *types.Signature:
+		return KindFunc
+	case *types.Chan:
+		return KindChan
+	}
+	return KindInvalid
+}
+
+// deref strips aliases and unnamed pointers from typ.
+// It reports whether at least one pointer was removed.
+func deref(typ types.Type) (types.Type, bool) {
+	pointer := false
+	for {
+		typ = types.Unalias(typ)
+		ptr, ok := typ.(*types.Pointer)
+		if !ok {
+			return typ, pointer
+		}
+		typ = ptr.Elem()
+		pointer = true
+	}
+}
+
+// baseOf strips aliases, unnamed pointers, slices and arrays from typ.
+// Named slices like pq.StringArray are returned as is.
+func baseOf(typ types.Type) types.Type {
+	for {
+		typ, _ = deref(typ)
+		switch t := typ.(type) {
+		case *types.Slice:
+			typ = t.Elem()
+		case *types.Array:
+			typ = t.Elem()
+		default:
+			return typ
+		}
+	}
+}
+
+// elemOf returns the element type of slices, arrays and maps or nil.
+func elemOf(typ types.Type) types.Type {
+	switch t := typ.Underlying().(type) {
+	case *types.Slice:
+		return t.Elem()
+	case *types.Array:
+		return t.Elem()
+	case *types.Map:
+		return t.Elem()
+	}
+	return nil
+}
+
+// pkgPathOf returns the import path of the package declaring named type typ.
+func pkgPathOf(typ types.Type) string {
+	if named, ok := typ.(*types.Named); ok && named.Obj().Pkg() != nil {
+		return named.Obj().Pkg().Path()
+	}
+	return ""
+}
+
+// isTextMarshaler reports whether typ or *typ has a MarshalText method.
+func isTextMarshaler(typ types.Type) bool {
+	if _, ok := typ.(*types.Named); !ok {
+		return false
+	}
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(typ), true, nil, "MarshalText")
+	_, ok := obj.(*types.Func)
+	return ok
+}
+
+// isNullType reports whether named type typ is known to map to a NULL-able column.
+func isNullType(typ types.Type) bool {
+	named, ok := typ.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return false
+	}
+
+	name := named.Obj().Name()
+	switch named.Obj().Pkg().Path() {
+	case "database/sql":
+		return strings.HasPrefix(name, "Null")
+	case "gorm.io/gorm":
+		return name == "DeletedAt"
+	case "github.com/google/uuid":
+		return name == "NullUUID"
+	}
+	return false
+}
+
+// relativeTo returns a qualifier that omits the package name for types declared in pkg.
+func relativeTo(pkg *types.Package) types.Qualifier {
+	return func(other *types.Package) string {
+		if other == pkg {
+			return ""
+		}
+		return other.Name()
+	}
+}
+
+// qualifyAll is a qualifier that always writes the package name.
+func qualifyAll(other *types.Package) string {
+	return other.Name()
+}
+
+// resolveType fills the type information of f from typ.
+// Type strings are relative to pkg, the package declaring the model.
+func resolveType(f *Field, typ types.Type, pkg *types.Package) {
+	qualifier := relativeTo(pkg)
+
+	f.Type = types.TypeString(typ, qualifier)
+	f.QualifiedType = types.TypeString(typ, qualifyAll)
+
+	base := baseOf(typ)
+	f.BaseType = types.TypeString(base, qualifier)
+	f.PkgPath = pkgPathOf(base)
+
+	t, pointer := deref(typ)
+	f.Kind = kindOf(t)
+	f.Nullable = pointer || isNullType(t)
+	f.TextMarshaler = isTextMarshaler(t)
+	f.Comparable = types.Comparable(typ)
+	if f.Kind.IsBasic() {
+		f.Underlying = t.Underlying().String()
+	}
+
+	if elem := elemOf(t); elem != nil {
+		elem, _ = deref(elem)
+		f.ElemType = types.TypeString(elem, qualifier)
+		f.ElemKind = kindOf(elem)
+	}
+}
+
+// isSupportedKind reports whether fields of kind k can be mapped to a column or relation.
+func isSupportedKind(k Kind) bool {
+	switch k {
+	case KindMap, KindInterface, KindFunc, KindChan, KindComplex, KindInvalid:
+		return false
+	}
+	return true
+}
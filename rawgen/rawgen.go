@@ -1,18 +1,26 @@
 // Package rawgen generates raw PostgreSQL Go code for a given model struct.
 // It produces functions that use database/sql directly (no ORM) for
-// Insert, QueryOne, Query, Delete, and Update operations.
+// Insert, QueryOne, Query, Delete, and Update operations, plus SoftDelete and
+// Restore for models with a gorm.DeletedAt field.
 package rawgen
 
 import (
 	"fmt"
+	"go/token"
 	"io"
+	"path"
 	"slices"
 	"strings"
+	"unicode"
 
 	"github.com/abiiranathan/apigen/parser"
 	"github.com/iancoleman/strcase"
+	"gorm.io/gorm/schema"
 )
 
+// defaultNamer names tables and columns of models without parsed names like GORM does by default.
+var defaultNamer = schema.NamingStrategy{}
+
 // Options controls what code is generated.
 type Options struct {
 	// ModelName is the struct name to generate for (e.g. "User").
@@ -32,13 +40,14 @@ type Options struct {
 	// replaced with the correct positional parameter number.
 	Filters []Filter
 
-	// TableName overrides the default snake_case plural table name.
+	// TableName overrides the table of the model. It defaults to StructMeta.Table,
+	// the TableName() of the model or the name given by the configured naming strategy.
 	TableName string
 }
 
 // Filter represents a custom WHERE clause added to Query functions.
 type Filter struct {
-	// Column is the Go field name (will be snake_cased for SQL).
+	// Column is the Go field name. The SQL column is the column of the field in the model.
 	Column string
 	// Op is the SQL operator (e.g. "=", ">", "ILIKE", "IN").
 	Op string
@@ -50,12 +59,16 @@ type Filter struct {
 
 // column holds resolved column metadata for code generation.
 type column struct {
-	GoName   string // e.g. "Name"
+	GoName   string // e.g. "Name" or "Author.Name" for embedded structs
 	ColName  string // e.g. "name"
 	GoType   string // e.g. "string"
 	IsFK     bool   // foreign key / relation field — excluded from raw queries
 	IsPK     bool
+	IsAuto   bool // auto-increment primary key generated by the database
+	Hidden   bool // apigen:hidden column, inserted but never selected nor updated
+	Version  bool // optimistic locking version, incremented by Update. See parser.StructMeta.VersionField
 	BaseType string
+	PkgPath  string // import path of the package declaring the type e.g "github.com/google/uuid"
 }
 
 // Generate writes raw PostgreSQL Go code to w for the specified model.
@@ -74,7 +87,10 @@ func Generate(w io.Writer, meta []parser.StructMeta, opts Options) error {
 
 	tableName := opts.TableName
 	if tableName == "" {
-		tableName = strcase.ToSnake(opts.ModelName) + "s"
+		tableName = target.Table
+	}
+	if tableName == "" {
+		tableName = defaultNamer.TableName(opts.ModelName)
 	}
 
 	cols := resolveColumns(target, opts)
@@ -93,21 +109,40 @@ func Generate(w io.Writer, meta []parser.StructMeta, opts Options) error {
 		TableName:    tableName,
 		Ident:        ident,
 		Columns:      cols,
-		PKCol:        findPK(cols),
+		PKCols:       findPKs(cols),
+		AutoCol:      findAuto(cols),
+		VersionCol:   findVersion(cols),
 		Filters:      opts.Filters,
+		FilterCols:   filterColumns(target, opts.Filters),
+		ReadOnly:     target.ReadOnly,
 	}
+	if f, ok := target.DeletedAt(); ok {
+		data.DeletedAt = f.Column
+	}
+	data.PKParams = pkParamNames(data)
 
 	return writeCode(w, data)
 }
 
 func resolveColumns(st *parser.StructMeta, opts Options) []column {
+	pks := st.PrimaryKeyFields()
+	isPK := func(f parser.Field) bool {
+		return slices.ContainsFunc(pks, func(pk parser.Field) bool {
+			return pk.Name == f.Name && pk.EmbeddedPath == f.EmbeddedPath
+		})
+	}
+	version, hasVersion := st.VersionField()
+
 	cols := make([]column, 0, len(st.Fields))
 	for _, f := range st.Fields {
-		if f.Preload {
-			continue // skip relation fields
+		if f.Preload || f.Embedded || f.Gorm.Ignore {
+			continue // skip relation fields, ignored fields and named embedded structs (their columns follow)
 		}
 
-		colName := strcase.ToSnake(f.Name)
+		colName := f.Column
+		if colName == "" {
+			colName = defaultNamer.ColumnName("", f.Name)
+		}
 
 		if len(opts.SelectFields) > 0 && !slices.Contains(opts.SelectFields, f.Name) {
 			continue
@@ -117,20 +152,91 @@ func resolveColumns(st *parser.StructMeta, opts Options) []column {
 		}
 
 		cols = append(cols, column{
-			GoName:   f.Name,
+			GoName:   selector(f),
 			ColName:  colName,
-			GoType:   f.Type,
+			GoType:   goType(f),
 			IsFK:     f.Preload,
-			IsPK:     f.Name == "ID",
+			IsPK:     isPK(f),
+			IsAuto:   isPK(f) && isAutoIncrement(f, len(pks)),
+			Hidden:   f.Hidden,
+			Version:  hasVersion && f.Name == version.Name && f.EmbeddedPath == version.EmbeddedPath,
 			BaseType: f.BaseType,
+			PkgPath:  f.PkgPath,
 		})
 	}
 	return cols
 }
 
-func findPK(cols []column) *column {
+// selector returns the Go selector of f relative to the model e.g "Author.Name".
+func selector(f parser.Field) string {
+	if f.EmbeddedPath != "" {
+		return f.EmbeddedPath + "." + f.Name
+	}
+	return f.Name
+}
+
+// goType returns the type of f as written outside the model package.
+func goType(f parser.Field) string {
+	if f.QualifiedType != "" {
+		return f.QualifiedType
+	}
+	return f.Type
+}
+
+// isAutoIncrement reports whether the database generates primary key f.
+// Like GORM, a single integer key is auto-incremented unless tagged otherwise.
+func isAutoIncrement(f parser.Field, numKeys int) bool {
+	if f.Gorm.AutoIncrement {
+		return true
+	}
+
+	switch f.Kind {
+	case parser.KindInt, parser.KindUint:
+		return numKeys == 1
+	case parser.KindInvalid:
+		// Metadata without resolved types, assume the conventional integer ID.
+		return numKeys == 1 && f.Name == "ID"
+	}
+	return false
+}
+
+// filterColumns returns the SQL column of each filter, honoring column tags of the model.
+func filterColumns(st *parser.StructMeta, filters []Filter) []string {
+	cols := make([]string, len(filters))
+	for i, filter := range filters {
+		cols[i] = defaultNamer.ColumnName("", filter.Column)
+		for _, f := range st.Fields {
+			if f.Name == filter.Column && f.Column != "" {
+				cols[i] = f.Column
+				break
+			}
+		}
+	}
+	return cols
+}
+
+func findPKs(cols []column) []column {
+	var pks []column
+	for _, c := range cols {
+		if c.IsPK {
+			pks = append(pks, c)
+		}
+	}
+	return pks
+}
+
+func findVersion(cols []column) *column {
+	for i := range cols {
+		if cols[i].Version {
+			return &cols[i]
+		}
+	}
+	return nil
+}
+
+func findAuto(cols []column) *column {
 	for i := range cols {
-		if cols[i].IsPK {
+		if cols[i].IsAuto {
 			return &cols[i]
 		}
 	}
@@ -144,8 +250,28 @@ type templateData struct {
 	TableName    string
 	Ident        string
 	Columns      []column
-	PKCol        *column
+	PKCols       []column // Primary key columns, one for single-column keys
+	PKParams     []string // Function parameter name of each primary key column
+	AutoCol      *column  // Auto-increment primary key returned by INSERT
+	VersionCol   *column  // Optimistic locking version checked and incremented by Update
 	Filters      []Filter
+	FilterCols   []string // SQL column of each filter
+	ReadOnly     bool     // Only generate queries for apigen:readonly models
+	DeletedAt    string   // Column of the gorm.DeletedAt field. Soft deleted rows are left out of queries
+}
+
+// versioned reports whether Update checks and increments the version column.
+func (d templateData) versioned() bool {
+	return d.VersionCol != nil && len(d.PKCols) > 0 && !d.ReadOnly
+}
+
+// live returns the condition matching rows that are not soft deleted, prefixed with " AND ".
+// It is empty for models without a gorm.DeletedAt field.
+func (d templateData) live() string {
+	if d.DeletedAt == "" {
+		return ""
+	}
+	return " AND " + d.DeletedAt + " IS NULL"
 }
 
 func writeCode(w io.Writer, d templateData) error {
@@ -159,19 +285,40 @@ func writeCode(w io.Writer, d templateData) error {
 	p("import (\n")
 	p("\t\"context\"\n")
 	p("\t\"database/sql\"\n")
+	if d.versioned() {
+		p("\t\"errors\"\n")
+	}
 	p("\t\"fmt\"\n")
 	if len(d.Filters) > 0 {
 		p("\t\"strings\"\n")
 	}
 	p("\n")
 	p("\t%q\n", d.ModelPkg)
+	for _, path := range pkImports(d) {
+		p("\t%q\n", path)
+	}
+	if d.versioned() {
+		// Stale updates return the error of the GORM services.
+		p("\t\"github.com/abiiranathan/apigen/repo\"\n")
+	}
 	p(")\n\n")
 
-	writeInsert(w, d)
-	if d.PKCol != nil {
+	if !d.ReadOnly {
+		writeInsert(w, d)
+	}
+	if len(d.PKCols) > 0 {
 		writeQueryOne(w, d)
-		writeDelete(w, d)
-		writeUpdate(w, d)
+		if !d.ReadOnly {
+			writeDelete(w, d)
+			if d.DeletedAt != "" {
+				writeSoftDelete(w, d)
+			}
+			if d.versioned() {
+				writeVersionUpdate(w, d)
+			} else {
+				writeUpdate(w, d)
+			}
+		}
 	}
 	writeQuery(w, d)
 
@@ -183,7 +330,7 @@ func writeInsert(w io.Writer, d templateData) {
 	p := func(format string, args ...any) { fmt.Fprintf(w, format, args...) }
 
 	// Columns to insert (exclude PK if autoIncrement)
-	insertCols := nonPKCols(d.Columns)
+	insertCols := nonAutoCols(d.Columns)
 
 	colNames := make([]string, len(insertCols))
 	placeholders := make([]string, len(insertCols))
@@ -199,8 +346,8 @@ func writeInsert(w io.Writer, d templateData) {
 		d.ModelName, d.Ident, d.ModelPkgName, d.ModelName)
 	p("\tconst query = `INSERT INTO %s (%s) VALUES (%s)", d.TableName,
 		strings.Join(colNames, ", "), strings.Join(placeholders, ", "))
-	if d.PKCol != nil {
-		p(" RETURNING %s", d.PKCol.ColName)
+	if d.AutoCol != nil {
+		p(" RETURNING %s", d.AutoCol.ColName)
 	}
 	p("`\n")
 
@@ -210,7 +357,7 @@ func writeInsert(w io.Writer, d templateData) {
 		args[i] = fmt.Sprintf("%s.%s", d.Ident, c.GoName)
 	}
 
-	if d.PKCol != nil {
+	if d.AutoCol != nil {
 		p("\treturn db.QueryRowContext(ctx, query,\n")
 		for i, a := range args {
 			if i < len(args)-1 {
@@ -219,7 +366,7 @@ func writeInsert(w io.Writer, d templateData) {
 				p("\t\t%s,\n", a)
 			}
 		}
-		p("\t).Scan(&%s.%s)\n", d.Ident, d.PKCol.GoName)
+		p("\t).Scan(&%s.%s)\n", d.Ident, d.AutoCol.GoName)
 	} else {
 		p("\t_, err := db.ExecContext(ctx, query,\n")
 		for _, a := range args {
@@ -235,16 +382,17 @@ func writeInsert(w io.Writer, d templateData) {
 func writeQueryOne(w io.Writer, d templateData) {
 	p := func(format string, args ...any) { fmt.Fprintf(w, format, args...) }
 
-	colList := columnList(d.Columns)
-	scanFields := scanFieldList(d.Columns, d.Ident)
+	selectCols := visibleCols(d.Columns)
+	colList := columnList(selectCols)
+	scanFields := scanFieldList(selectCols, d.Ident)
 
 	p("// Get%s retrieves a single %s by primary key.\n", d.ModelName, d.ModelName)
-	p("func Get%s(ctx context.Context, db *sql.DB, id %s) (*%s.%s, error) {\n",
-		d.ModelName, d.PKCol.GoType, d.ModelPkgName, d.ModelName)
-	p("\tconst query = `SELECT %s FROM %s WHERE %s = $1`\n",
-		colList, d.TableName, d.PKCol.ColName)
+	p("func Get%s(ctx context.Context, db *sql.DB, %s) (*%s.%s, error) {\n",
+		d.ModelName, pkParams(d), d.ModelPkgName, d.ModelName)
+	p("\tconst query = `SELECT %s FROM %s WHERE %s%s`\n",
+		colList, d.TableName, pkCondition(d.PKCols, 1), d.live())
 	p("\tvar %s %s.%s\n", d.Ident, d.ModelPkgName, d.ModelName)
-	p("\terr := db.QueryRowContext(ctx, query, id).Scan(\n")
+	p("\terr := db.QueryRowContext(ctx, query, %s).Scan(\n", pkArgs(d))
 	p("\t\t%s,\n", scanFields)
 	p("\t)\n")
 	p("\tif err != nil {\n")
@@ -258,8 +406,9 @@ func writeQueryOne(w io.Writer, d templateData) {
 func writeQuery(w io.Writer, d templateData) {
 	p := func(format string, args ...any) { fmt.Fprintf(w, format, args...) }
 
-	colList := columnList(d.Columns)
-	scanFields := scanFieldList(d.Columns, d.Ident)
+	selectCols := visibleCols(d.Columns)
+	colList := columnList(selectCols)
+	scanFields := scanFieldList(selectCols, d.Ident)
 
 	// Build function signature with filter params
 	var params strings.Builder
@@ -277,16 +426,22 @@ func writeQuery(w io.Writer, d templateData) {
 
 	p("// Query%ss retrieves %s records matching the provided filters.\n", d.ModelName, d.ModelName)
 	p("func Query%ss(%s) ([]*%s.%s, error) {\n", d.ModelName, params.String(), d.ModelPkgName, d.ModelName)
-	p("\tbaseQuery := `SELECT %s FROM %s`\n", colList, d.TableName)
+	p("\tbaseQuery := `SELECT %s FROM %s", colList, d.TableName)
+	where := " WHERE "
+	if d.DeletedAt != "" {
+		p(" WHERE %s IS NULL", d.DeletedAt)
+		where = " AND "
+	}
+	p("`\n")
 
 	if len(d.Filters) > 0 {
 		p("\n\tconditions := make([]string, 0, %d)\n", len(d.Filters))
 		p("\targs := make([]any, 0, %d)\n", len(d.Filters))
 		p("\tparamIdx := 1\n\n")
 
-		for _, f := range d.Filters {
+		for i, f := range d.Filters {
 			paramName := strcase.ToLowerCamel(f.Column)
-			colName := strcase.ToSnake(f.Column)
+			colName := d.FilterCols[i]
 			if f.Nullable {
 				p("\tif %s != nil {\n", paramName)
 				p("\t\tconditions = append(conditions, fmt.Sprintf(\"%s %s $%%d\", paramIdx))\n", colName, f.Op)
@@ -301,7 +456,7 @@ func writeQuery(w io.Writer, d templateData) {
 		}
 
 		p("\tif len(conditions) > 0 {\n")
-		p("\t\tbaseQuery += \" WHERE \" + strings.Join(conditions, \" AND \")\n")
+		p("\t\tbaseQuery += %q + strings.Join(conditions, \" AND \")\n", where)
 		p("\t}\n\n")
 		p("\trows, err := db.QueryContext(ctx, baseQuery, args...)\n")
 	} else {
@@ -330,11 +485,38 @@ func writeQuery(w io.Writer, d templateData) {
 func writeDelete(w io.Writer, d templateData) {
 	p := func(format string, args ...any) { fmt.Fprintf(w, format, args...) }
 
-	p("// Delete%s deletes a %s by primary key.\n", d.ModelName, d.ModelName)
-	p("func Delete%s(ctx context.Context, db *sql.DB, id %s) error {\n",
-		d.ModelName, d.PKCol.GoType)
-	p("\tconst query = `DELETE FROM %s WHERE %s = $1`\n", d.TableName, d.PKCol.ColName)
-	p("\tresult, err := db.ExecContext(ctx, query, id)\n")
+	p("// Delete%s permanently deletes a %s by primary key.\n", d.ModelName, d.ModelName)
+	p("func Delete%s(ctx context.Context, db *sql.DB, %s) error {\n",
+		d.ModelName, pkParams(d))
+	p("\tconst query = `DELETE FROM %s WHERE %s`\n", d.TableName, pkCondition(d.PKCols, 1))
+	writeExecRow(w, d)
+}
+
+// --- SOFT DELETE ---
+func writeSoftDelete(w io.Writer, d templateData) {
+	p := func(format string, args ...any) { fmt.Fprintf(w, format, args...) }
+
+	p("// SoftDelete%s sets the %s column of a %s by primary key.\n", d.ModelName, d.DeletedAt, d.ModelName)
+	p("func SoftDelete%s(ctx context.Context, db *sql.DB, %s) error {\n",
+		d.ModelName, pkParams(d))
+	p("\tconst query = `UPDATE %s SET %s = NOW() WHERE %s%s`\n",
+		d.TableName, d.DeletedAt, pkCondition(d.PKCols, 1), d.live())
+	writeExecRow(w, d)
+
+	p("// Restore%s clears the %s column of a soft deleted %s by primary key.\n", d.ModelName, d.DeletedAt, d.ModelName)
+	p("func Restore%s(ctx context.Context, db *sql.DB, %s) error {\n",
+		d.ModelName, pkParams(d))
+	p("\tconst query = `UPDATE %s SET %s = NULL WHERE %s AND %s IS NOT NULL`\n",
+		d.TableName, d.DeletedAt, pkCondition(d.PKCols, 1), d.DeletedAt)
+	writeExecRow(w, d)
+}
+
+// writeExecRow writes the end of a function running query for the row with the primary key
+// parameters, returning sql.ErrNoRows when no row is affected.
+func writeExecRow(w io.Writer, d templateData) {
+	p := func(format string, args ...any) { fmt.Fprintf(w, format, args...) }
+
+	p("\tresult, err := db.ExecContext(ctx, query, %s)\n", pkArgs(d))
 	p("\tif err != nil {\n")
 	p("\t\treturn err\n")
 	p("\t}\n")
@@ -353,23 +535,24 @@ func writeDelete(w io.Writer, d templateData) {
 func writeUpdate(w io.Writer, d templateData) {
 	p := func(format string, args ...any) { fmt.Fprintf(w, format, args...) }
 
-	updateCols := nonPKCols(d.Columns)
+	updateCols := visibleCols(nonPKCols(d.Columns))
 	setClauses := make([]string, len(updateCols))
 	for i, c := range updateCols {
 		setClauses[i] = fmt.Sprintf("%s = $%d", c.ColName, i+1)
 	}
-	pkParam := fmt.Sprintf("$%d", len(updateCols)+1)
 
 	p("// Update%s updates all columns of a %s by primary key.\n", d.ModelName, d.ModelName)
 	p("func Update%s(ctx context.Context, db *sql.DB, %s *%s.%s) error {\n",
 		d.ModelName, d.Ident, d.ModelPkgName, d.ModelName)
-	p("\tconst query = `UPDATE %s SET %s WHERE %s = %s`\n",
-		d.TableName, strings.Join(setClauses, ", "), d.PKCol.ColName, pkParam)
+	p("\tconst query = `UPDATE %s SET %s WHERE %s%s`\n",
+		d.TableName, strings.Join(setClauses, ", "), pkCondition(d.PKCols, len(updateCols)+1), d.live())
 	p("\tresult, err := db.ExecContext(ctx, query,\n")
 	for _, c := range updateCols {
 		p("\t\t%s.%s,\n", d.Ident, c.GoName)
 	}
-	p("\t\t%s.%s,\n", d.Ident, d.PKCol.GoName)
+	for _, c := range d.PKCols {
+		p("\t\t%s.%s,\n", d.Ident, c.GoName)
+	}
 	p("\t)\n")
 	p("\tif err != nil {\n")
 	p("\t\treturn err\n")
@@ -385,6 +568,61 @@ func writeUpdate(w io.Writer, d templateData) {
 	p("}\n\n")
 }
 
+// --- UPDATE with optimistic locking ---
+func writeVersionUpdate(w io.Writer, d templateData) {
+	p := func(format string, args ...any) { fmt.Fprintf(w, format, args...) }
+
+	version := d.VersionCol.ColName
+	var updateCols []column
+	for _, c := range visibleCols(nonPKCols(d.Columns)) {
+		if !c.Version {
+			updateCols = append(updateCols, c)
+		}
+	}
+	setClauses := make([]string, 0, len(updateCols)+1)
+	for i, c := range updateCols {
+		setClauses = append(setClauses, fmt.Sprintf("%s = $%d", c.ColName, i+1))
+	}
+	setClauses = append(setClauses, fmt.Sprintf("%s = %s + 1", version, version))
+
+	next := len(updateCols) + len(d.PKCols) + 1
+	p("// Update%s updates all columns of a %s by primary key if its %s is the current one,\n", d.ModelName, d.ModelName, version)
+	p("// incrementing it. Returns repo.ErrStaleObject if the %s was updated since it was read.\n", d.ModelName)
+	p("func Update%s(ctx context.Context, db *sql.DB, %s *%s.%s) error {\n",
+		d.ModelName, d.Ident, d.ModelPkgName, d.ModelName)
+	p("\tconst query = `UPDATE %s SET %s WHERE %s AND %s = $%d%s RETURNING %s`\n",
+		d.TableName, strings.Join(setClauses, ", "), pkCondition(d.PKCols, len(updateCols)+1),
+		version, next, d.live(), version)
+	p("\terr := db.QueryRowContext(ctx, query,\n")
+	for _, c := range updateCols {
+		p("\t\t%s.%s,\n", d.Ident, c.GoName)
+	}
+	for _, c := range d.PKCols {
+		p("\t\t%s.%s,\n", d.Ident, c.GoName)
+	}
+	p("\t\t%s.%s,\n", d.Ident, d.VersionCol.GoName)
+	p("\t).Scan(&%s.%s)\n", d.Ident, d.VersionCol.GoName)
+	p("\tif !errors.Is(err, sql.ErrNoRows) {\n")
+	p("\t\treturn err\n")
+	p("\t}\n\n")
+
+	// No row matched: the row is gone or its version changed.
+	pkValues := make([]string, len(d.PKCols))
+	for i, c := range d.PKCols {
+		pkValues[i] = fmt.Sprintf("%s.%s", d.Ident, c.GoName)
+	}
+	p("\tconst exists = `SELECT EXISTS (SELECT 1 FROM %s WHERE %s%s)`\n", d.TableName, pkCondition(d.PKCols, 1), d.live())
+	p("\tvar found bool\n")
+	p("\tif err := db.QueryRowContext(ctx, exists, %s).Scan(&found); err != nil {\n", strings.Join(pkValues, ", "))
+	p("\t\treturn err\n")
+	p("\t}\n")
+	p("\tif !found {\n")
+	p("\t\treturn sql.ErrNoRows\n")
+	p("\t}\n")
+	p("\treturn repo.ErrStaleObject\n")
+	p("}\n\n")
+}
+
 // --- helpers ---
 
 func nonPKCols(cols []column) []column {
@@ -397,6 +635,106 @@ func nonPKCols(cols []column) []column {
 	return out
 }
 
+// visibleCols returns cols without apigen:hidden columns.
+func visibleCols(cols []column) []column {
+	out := make([]column, 0, len(cols))
+	for _, c := range cols {
+		if !c.Hidden {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// pkImports returns the packages declaring primary key types used as function parameters,
+// other than the model package e.g "github.com/google/uuid".
+func pkImports(d templateData) []string {
+	var imports []string
+	for _, c := range d.PKCols {
+		if c.PkgPath != "" && c.PkgPath != d.ModelPkg && !slices.Contains(imports, c.PkgPath) {
+			imports = append(imports, c.PkgPath)
+		}
+	}
+	return imports
+}
+
+func nonAutoCols(cols []column) []column {
+	out := make([]column, 0, len(cols))
+	for _, c := range cols {
+		if !c.IsAuto {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// pkParamNames returns the function parameter names of the primary key columns.
+// Single-column keys keep the conventional "id". Names that are Go keywords or clash with
+// the identifiers of the generated functions e.g "type" or "db" get a "Key" suffix.
+func pkParamNames(d templateData) []string {
+	if len(d.PKCols) == 1 {
+		return []string{"id"}
+	}
+
+	reserved := []string{
+		"ctx", "db", "query", "err", "result", "rows", "nil",
+		"context", "sql", "errors", "fmt", "strings", "repo", d.ModelPkgName, d.Ident,
+	}
+	for _, pkg := range pkImports(d) {
+		reserved = append(reserved, path.Base(pkg))
+	}
+
+	names := make([]string, len(d.PKCols))
+	for i, c := range d.PKCols {
+		name := lowerInitial(strings.ReplaceAll(c.GoName, ".", ""))
+		for token.IsKeyword(name) || slices.Contains(reserved, name) || slices.Contains(names[:i], name) {
+			name += "Key"
+		}
+		names[i] = name
+	}
+	return names
+}
+
+// lowerInitial lowercases the leading initialism of a Go name
+// e.g "OrderID" -> "orderID" and "URLPath" -> "urlPath".
+func lowerInitial(name string) string {
+	runes := []rune(name)
+	for i := range runes {
+		if !unicode.IsUpper(runes[i]) {
+			break
+		}
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+// pkParams returns the parameter list of the primary key e.g "orderID int64, productID int64".
+func pkParams(d templateData) string {
+	params := make([]string, len(d.PKCols))
+	for i, c := range d.PKCols {
+		params[i] = d.PKParams[i] + " " + c.GoType
+	}
+	return strings.Join(params, ", ")
+}
+
+// pkArgs returns the primary key parameters as call arguments.
+func pkArgs(d templateData) string {
+	return strings.Join(d.PKParams, ", ")
+}
+
+// pkCondition returns the WHERE condition matching the primary key,
+// numbering placeholders from start e.g "order_id = $1 AND product_id = $2".
+func pkCondition(pks []column, start int) string {
+	conds := make([]string, len(pks))
+	for i, c := range pks {
+		conds[i] = fmt.Sprintf("%s = $%d", c.ColName, start+i)
+	}
+	return strings.Join(conds, " AND ")
+}
+
 func columnList(cols []column) string {
 	names := make([]string, len(cols))
 	for i, c := range cols {
@@ -2,6 +2,7 @@ package rawgen
 
 import (
 	"bytes"
+	"go/format"
 	"strings"
 	"testing"
 
@@ -411,3 +412,303 @@ func TestCustomPackageName(t *testing.T) {
 	has(t, out, "domain.Item")
 	has(t, out, `"github.com/example/myapp/domain"`)
 }
+
+func TestEmbeddedFields(t *testing.T) {
+	meta := []parser.StructMeta{
+		{
+			Name:    "Invoice",
+			PKType:  "uint",
+			Package: "github.com/example/app/models",
+			Fields: []parser.Field{
+				{Name: "ID", Type: "uint", BaseType: "uint", Column: "id", Parent: "Invoice"},
+				{Name: "CreatedAt", Type: "time.Time", BaseType: "time.Time", Column: "created_at", Parent: "Invoice"},
+				{Name: "Owner", Type: "Contact", BaseType: "Contact", Embedded: true, Parent: "Invoice"},
+				{Name: "Name", Type: "string", BaseType: "string", Column: "owner_name", EmbeddedPath: "Owner", Parent: "Invoice"},
+				{Name: "Total", Type: "float64", BaseType: "float64", Column: "total", Gorm: parser.GormTag{Ignore: true}, Parent: "Invoice"},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := Generate(&buf, meta, Options{
+		ModelName: "Invoice",
+		ModelPkg:  "github.com/example/app/models",
+	})
+	if err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+	out := buf.String()
+
+	has(t, out, "func GetInvoice(ctx context.Context, db *sql.DB, id uint)")
+	has(t, out, "SELECT id, created_at, owner_name FROM invoices WHERE id = $1")
+	has(t, out, "&i.ID, &i.CreatedAt, &i.Owner.Name")
+	has(t, out, "UPDATE invoices SET created_at = $1, owner_name = $2 WHERE id = $3")
+	hasNot(t, out, "owner,")
+	hasNot(t, out, "total")
+}
+
+func TestCompositePrimaryKey(t *testing.T) {
+	meta := []parser.StructMeta{
+		{
+			Name:    "OrderItem",
+			Package: "github.com/example/app/models",
+			Fields: []parser.Field{
+				{Name: "OrderID", Type: "int64", BaseType: "int64", Kind: parser.KindInt, PrimaryKey: true, Parent: "OrderItem"},
+				{Name: "ProductID", Type: "int64", BaseType: "int64", Kind: parser.KindInt, PrimaryKey: true, Parent: "OrderItem"},
+				{Name: "Quantity", Type: "int", BaseType: "int", Kind: parser.KindInt, Parent: "OrderItem"},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := Generate(&buf, meta, Options{ModelName: "OrderItem", ModelPkg: "github.com/example/app/models"})
+	if err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+	out := buf.String()
+
+	// Composite keys are not generated by the database: insert every column.
+	has(t, out, "INSERT INTO order_items (order_id, product_id, quantity) VALUES ($1, $2, $3)`")
+	hasNot(t, out, "RETURNING")
+	has(t, out, "func GetOrderItem(ctx context.Context, db *sql.DB, orderID int64, productID int64)")
+	has(t, out, "WHERE order_id = $1 AND product_id = $2`")
+	has(t, out, "db.QueryRowContext(ctx, query, orderID, productID)")
+	has(t, out, "func DeleteOrderItem(ctx context.Context, db *sql.DB, orderID int64, productID int64)")
+	has(t, out, "UPDATE order_items SET quantity = $1 WHERE order_id = $2 AND product_id = $3")
+}
+
+func TestCompositePrimaryKeyParamNames(t *testing.T) {
+	meta := []parser.StructMeta{
+		{
+			Name:    "Setting",
+			Package: "github.com/example/app/models",
+			Fields: []parser.Field{
+				{Name: "Type", Type: "string", BaseType: "string", Kind: parser.KindString, PrimaryKey: true, Parent: "Setting"},
+				{Name: "DB", Type: "string", BaseType: "string", Kind: parser.KindString, PrimaryKey: true, Parent: "Setting"},
+				{Name: "Map", Type: "int", BaseType: "int", Kind: parser.KindInt, PrimaryKey: true, Parent: "Setting"},
+				{Name: "S", Type: "int", BaseType: "int", Kind: parser.KindInt, PrimaryKey: true, Parent: "Setting"},
+				{Name: "Value", Type: "string", BaseType: "string", Kind: parser.KindString, Parent: "Setting"},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Generate(&buf, meta, Options{ModelName: "Setting", ModelPkg: "github.com/example/app/models"}); err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+	out := buf.String()
+
+	// Keywords and the names of the generated functions, like the s of the scanned setting, get a suffix.
+	has(t, out, "func GetSetting(ctx context.Context, db *sql.DB, typeKey string, dbKey string, mapKey int, sKey int)")
+	has(t, out, "db.QueryRowContext(ctx, query, typeKey, dbKey, mapKey, sKey)")
+	has(t, out, "func DeleteSetting(ctx context.Context, db *sql.DB, typeKey string, dbKey string, mapKey int, sKey int)")
+	if _, err := format.Source(buf.Bytes()); err != nil {
+		t.Errorf("expected valid Go code, got %v", err)
+	}
+}
+
+func TestStringPrimaryKey(t *testing.T) {
+	meta := []parser.StructMeta{
+		{
+			Name:    "Country",
+			PKType:  "string",
+			Package: "github.com/example/app/models",
+			Fields: []parser.Field{
+				{Name: "Code", Type: "string", BaseType: "string", Kind: parser.KindString, PrimaryKey: true, Parent: "Country"},
+				{Name: "Name", Type: "string", BaseType: "string", Kind: parser.KindString, Parent: "Country"},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := Generate(&buf, meta, Options{ModelName: "Country", ModelPkg: "github.com/example/app/models"})
+	if err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+	out := buf.String()
+
+	has(t, out, "INSERT INTO countries (code, name) VALUES ($1, $2)`")
+	has(t, out, "func GetCountry(ctx context.Context, db *sql.DB, id string)")
+	has(t, out, "WHERE code = $1`")
+	has(t, out, "UPDATE countries SET name = $1 WHERE code = $2")
+}
+
+func TestImportedPrimaryKeyType(t *testing.T) {
+	meta := []parser.StructMeta{
+		{
+			Name:    "Device",
+			PKType:  "uuid.UUID",
+			Package: "github.com/example/app/models",
+			Fields: []parser.Field{
+				{Name: "ID", Type: "uuid.UUID", QualifiedType: "uuid.UUID", BaseType: "uuid.UUID", Kind: parser.KindArray, PkgPath: "github.com/google/uuid", PrimaryKey: true, Parent: "Device"},
+				{Name: "Name", Type: "string", BaseType: "string", Kind: parser.KindString, Parent: "Device"},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := Generate(&buf, meta, Options{ModelName: "Device", ModelPkg: "github.com/example/app/models"})
+	if err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+	out := buf.String()
+
+	has(t, out, "\t\"github.com/google/uuid\"\n")
+	has(t, out, "INSERT INTO devices (id, name) VALUES ($1, $2)`")
+	has(t, out, "func GetDevice(ctx context.Context, db *sql.DB, id uuid.UUID)")
+	has(t, out, "func DeleteDevice(ctx context.Context, db *sql.DB, id uuid.UUID)")
+}
+
+func TestTableAndColumnNames(t *testing.T) {
+	meta := []parser.StructMeta{
+		{
+			Name:    "Category",
+			PKType:  "uint",
+			Package: "github.com/example/app/models",
+			Fields: []parser.Field{
+				{Name: "ID", Type: "uint", BaseType: "uint", Kind: parser.KindUint, PrimaryKey: true, Parent: "Category"},
+				{Name: "Title", Type: "string", BaseType: "string", Kind: parser.KindString, Column: "category_title", Parent: "Category"},
+			},
+		},
+	}
+
+	generate := func(opts Options) string {
+		var buf bytes.Buffer
+		if err := Generate(&buf, meta, opts); err != nil {
+			t.Fatalf("Generate() error: %v", err)
+		}
+		return buf.String()
+	}
+
+	// GORM pluralization instead of appending "s".
+	out := generate(Options{ModelName: "Category", ModelPkg: "github.com/example/app/models", Filters: []Filter{{Column: "Title", Op: "=", GoType: "string"}}})
+	has(t, out, "FROM categories")
+	has(t, out, "SELECT id, category_title FROM")
+	has(t, out, `"category_title = $%d"`)
+
+	// Table resolved by the parser from TableName() or the naming strategy.
+	meta[0].Table = "app_category"
+	out = generate(Options{ModelName: "Category", ModelPkg: "github.com/example/app/models"})
+	has(t, out, "FROM app_category")
+	has(t, out, "INSERT INTO app_category")
+}
+
+func TestDirectives(t *testing.T) {
+	meta := []parser.StructMeta{
+		{
+			Name:    "Member",
+			PKType:  "uint",
+			Package: "github.com/example/app/models",
+			Table:   "club_members", // apigen:table=club_members
+			Fields: []parser.Field{
+				{Name: "ID", Type: "uint", BaseType: "uint", Kind: parser.KindUint, PrimaryKey: true, Parent: "Member"},
+				{Name: "Email", Type: "string", BaseType: "string", Kind: parser.KindString, Parent: "Member"},
+				{Name: "Password", Type: "string", BaseType: "string", Kind: parser.KindString, Hidden: true, Parent: "Member"},
+			},
+		},
+		{
+			Name:     "MemberStats",
+			PKType:   "uint",
+			Package:  "github.com/example/app/models",
+			ReadOnly: true,
+			Fields: []parser.Field{
+				{Name: "MemberID", Type: "uint", BaseType: "uint", Kind: parser.KindUint, PrimaryKey: true, Parent: "MemberStats"},
+				{Name: "Visits", Type: "int", BaseType: "int", Kind: parser.KindInt, Parent: "MemberStats"},
+			},
+		},
+	}
+
+	generate := func(model string) string {
+		var buf bytes.Buffer
+		if err := Generate(&buf, meta, Options{ModelName: model, ModelPkg: "github.com/example/app/models"}); err != nil {
+			t.Fatalf("Generate() error: %v", err)
+		}
+		return buf.String()
+	}
+
+	// Hidden columns are inserted but never selected nor overwritten.
+	out := generate("Member")
+	has(t, out, "INSERT INTO club_members (email, password) VALUES ($1, $2) RETURNING id`")
+	has(t, out, "SELECT id, email FROM club_members WHERE id = $1`")
+	has(t, out, "baseQuery := `SELECT id, email FROM club_members`")
+	has(t, out, "UPDATE club_members SET email = $1 WHERE id = $2`")
+	hasNot(t, out, "&m.Password")
+
+	// Read-only models only get queries.
+	out = generate("MemberStats")
+	has(t, out, "func GetMemberStats(ctx context.Context, db *sql.DB, id uint)")
+	has(t, out, "func QueryMemberStatss(")
+	hasNot(t, out, "func InsertMemberStats(")
+	hasNot(t, out, "func UpdateMemberStats(")
+	hasNot(t, out, "func DeleteMemberStats(")
+}
+
+func TestSoftDelete(t *testing.T) {
+	meta := []parser.StructMeta{
+		{
+			Name:    "Invoice",
+			PKType:  "uint",
+			Package: "github.com/example/app/models",
+			Fields: []parser.Field{
+				{Name: "ID", Type: "uint", BaseType: "uint", Kind: parser.KindUint, PrimaryKey: true, Parent: "Invoice"},
+				{Name: "Total", Type: "int", BaseType: "int", Kind: parser.KindInt, Parent: "Invoice"},
+				{Name: "DeletedAt", Type: "gorm.DeletedAt", BaseType: "gorm.DeletedAt", PkgPath: "gorm.io/gorm", Column: "deleted_at", Parent: "Invoice"},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	opts := Options{
+		ModelName: "Invoice",
+		ModelPkg:  "github.com/example/app/models",
+		Filters:   []Filter{{Column: "Total", Op: ">", GoType: "int"}},
+	}
+	if err := Generate(&buf, meta, opts); err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+	out := buf.String()
+
+	// Soft deleted rows are left out of queries and updates.
+	has(t, out, "SELECT id, total, deleted_at FROM invoices WHERE id = $1 AND deleted_at IS NULL`")
+	has(t, out, "baseQuery := `SELECT id, total, deleted_at FROM invoices WHERE deleted_at IS NULL`")
+	has(t, out, `baseQuery += " AND " + strings.Join(conditions, " AND ")`)
+	has(t, out, "UPDATE invoices SET total = $1, deleted_at = $2 WHERE id = $3 AND deleted_at IS NULL`")
+
+	has(t, out, "func SoftDeleteInvoice(ctx context.Context, db *sql.DB, id uint) error {")
+	has(t, out, "UPDATE invoices SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`")
+	has(t, out, "func RestoreInvoice(ctx context.Context, db *sql.DB, id uint) error {")
+	has(t, out, "UPDATE invoices SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL`")
+	has(t, out, "DELETE FROM invoices WHERE id = $1`")
+}
+
+func TestOptimisticLocking(t *testing.T) {
+	meta := []parser.StructMeta{
+		{
+			Name:    "Document",
+			Package: "github.com/example/app/models",
+			Fields: []parser.Field{
+				{Name: "ID", Type: "uint", BaseType: "uint", Kind: parser.KindUint, PrimaryKey: true, Parent: "Document"},
+				{Name: "Title", Type: "string", BaseType: "string", Kind: parser.KindString, Parent: "Document"},
+				{Name: "Revision", Type: "int64", BaseType: "int64", Kind: parser.KindInt, Column: "revision", Version: true, Parent: "Document"},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Generate(&buf, meta, Options{ModelName: "Document", ModelPkg: "github.com/example/app/models"}); err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+	out := buf.String()
+
+	has(t, out, "\t\"errors\"\n")
+	has(t, out, "\t\"github.com/abiiranathan/apigen/repo\"\n")
+	has(t, out, "UPDATE documents SET title = $1, revision = revision + 1 WHERE id = $2 AND revision = $3 RETURNING revision`")
+	has(t, out, "\t\td.Revision,\n\t).Scan(&d.Revision)")
+	has(t, out, "SELECT EXISTS (SELECT 1 FROM documents WHERE id = $1)`")
+	has(t, out, "return repo.ErrStaleObject")
+	hasNot(t, out, "ErrStaleDocument")
+	has(t, out, "INSERT INTO documents (title, revision) VALUES ($1, $2)")
+
+	// Models without a version column keep the plain update.
+	hasNot(t, gen(t, Options{ModelName: "User", ModelPkg: "github.com/abiiranathan/apigen/models"}), "apigen/repo")
+}
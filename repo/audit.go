@@ -0,0 +1,310 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// AuditEntry is a row of the audit table recording a write of a model with Meta.Audit.
+// Create the table with gorm.DB.AutoMigrate(&repo.AuditEntry{}).
+type AuditEntry struct {
+	ID        uint              `json:"id" gorm:"primaryKey"`
+	Model     string            `json:"model" gorm:"size:100;index:idx_audit_record"`     // Name of the model type e.g "User"
+	RecordID  string            `json:"record_id" gorm:"size:255;index:idx_audit_record"` // Primary key, see Repo.RecordID
+	Actor     string            `json:"actor"`                                            // Actor of the context of the write, see WithActor
+	Tenant    string            `json:"tenant" gorm:"size:255"`                           // Tenant of models with Meta.Tenant, see WithTenant
+	Operation Operation         `json:"operation" gorm:"size:20"`
+	Changes   map[string]Change `json:"changes" gorm:"serializer:json"` // Changed columns, hidden ones excepted
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// TableName returns the table of the audit entries.
+func (AuditEntry) TableName() string {
+	return "audit_log"
+}
+
+// Change holds the values of a column before and after a write. Before is nil for
+// creates and After for deletes.
+type Change struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// auditedMethods are the methods writing an AuditEntry for models with Meta.Audit.
+var auditedMethods = []string{
+	"Create", "CreateMany", "FirstOrCreate", "Upsert", "UpsertMany", "Update", "PartialUpdate",
+	"PartialUpdateWithMap", "UpdateColumn", "Delete", "DeleteWhere", "SoftDelete", "ForceDelete", "Restore",
+}
+
+// actorKey is the context key of the actor of WithActor.
+type actorKey struct{}
+
+// WithActor returns a copy of ctx whose writes are audited as made by actor e.g a user id.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor returns the actor of ctx set with WithActor, empty if none.
+func Actor(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// audited returns whether the write of event is recorded in the audit table.
+func (r *Repo[T, PK]) audited(event *Event) bool {
+	return r.meta.Audit && r.meta.KeyCondition != nil && slices.Contains(auditedMethods, event.Method)
+}
+
+// snapshot returns the record matching cond as stored, nil if there is none.
+// Soft deleted records are only read if unscoped. Hidden columns are not read.
+func (r *Repo[T, PK]) snapshot(cond clause.Expression, unscoped bool) (*T, error) {
+	var record T
+	db := r.DB
+	if unscoped {
+		db = db.Unscoped()
+	}
+	db = db.Omit(r.meta.Hidden...).Where(cond).Limit(1).Find(&record)
+	if db.Error != nil || db.RowsAffected == 0 {
+		return nil, db.Error
+	}
+	return &record, nil
+}
+
+// stored returns the records written by event as stored before the write, nil for records
+// not stored yet: the record with the key of the event, the records matching the
+// conditions of DeleteWhere and UpdateColumn, or those with the conflict columns of the
+// upserted records.
+func (r *Repo[T, PK]) stored(event *Event, records []*T) ([]*T, error) {
+	switch {
+	case event.Key != nil:
+		// Only ForceDelete and Restore write soft deleted records.
+		unscoped := event.Method == "ForceDelete" || event.Method == "Restore"
+		record, err := r.snapshot(r.meta.KeyCondition(event.Key.(PK)), unscoped)
+		return []*T{record}, err
+	case event.where != nil:
+		var stored []*T
+		err := r.DB.Omit(r.meta.Hidden...).Where(event.where[0], event.where[1:]...).Find(&stored).Error
+		return stored, err
+	case event.Method == "Upsert" || event.Method == "UpsertMany":
+		stored := make([]*T, len(records))
+		for i, record := range records {
+			cond, err := r.conflictCondition(record)
+			if err != nil {
+				return nil, err
+			}
+			if stored[i], err = r.snapshot(cond, false); err != nil {
+				return nil, err
+			}
+		}
+		return stored, nil
+	}
+	return make([]*T, len(records)), nil
+}
+
+// conflictCondition returns the condition matching the conflict columns of record.
+func (r *Repo[T, PK]) conflictCondition(record *T) (clause.Expression, error) {
+	sch, err := r.schema()
+	if err != nil {
+		return nil, err
+	}
+	columns, err := r.conflictColumns(sch)
+	if err != nil {
+		return nil, err
+	}
+
+	value := reflect.ValueOf(record).Elem()
+	var exprs []clause.Expression
+	for _, column := range columns {
+		field := sch.LookUpField(column)
+		if field == nil {
+			return nil, fmt.Errorf("repo: unknown conflict column %q of %s", column, sch.Name)
+		}
+		fieldValue, _ := field.ValueOf(r.DB.Statement.Context, value)
+		exprs = append(exprs, clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: fieldValue})
+	}
+	return clause.And(exprs...), nil
+}
+
+// auditWrite writes the AuditEntry of the write of event for each record stored before it,
+// aligned with the records written by fn. The records of upserts, DeleteWhere and UpdateColumn
+// are read again after the write, as the written records don't hold the stored values.
+func (r *Repo[T, PK]) auditWrite(event *Event, stored, written []*T) error {
+	for i, before := range stored {
+		var after *T
+		var err error
+		switch {
+		case event.Operation == OpDelete:
+		case event.where != nil:
+			after, err = r.snapshot(r.meta.KeyCondition(r.meta.PrimaryKey(before)), false)
+		case event.Method == "Upsert" || event.Method == "UpsertMany":
+			var cond clause.Expression
+			if cond, err = r.conflictCondition(written[i]); err == nil {
+				after, err = r.snapshot(cond, false)
+			}
+		default:
+			after = written[i]
+		}
+		if err != nil {
+			return err
+		}
+		// Writes matching no record, like deletes of missing keys, are not recorded.
+		if before == nil && after == nil {
+			continue
+		}
+		if err := r.audit(event, before, after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// audit writes the AuditEntry of the write of event changing before into after,
+// either being nil for creates and deletes.
+func (r *Repo[T, PK]) audit(event *Event, before, after *T) error {
+	sch, err := r.schema()
+	if err != nil {
+		return err
+	}
+
+	var key PK
+	if event.Key != nil {
+		key = event.Key.(PK)
+	} else if after != nil {
+		key = r.meta.PrimaryKey(after)
+	} else if before != nil {
+		key = r.meta.PrimaryKey(before)
+	}
+	recordID, err := r.RecordID(key)
+	if err != nil {
+		return err
+	}
+	operation := event.Operation
+	if operation == OpCreate && before != nil {
+		// Upserts of stored records update them.
+		operation = OpUpdate
+	}
+
+	entry := &AuditEntry{
+		Model:     event.Model,
+		RecordID:  recordID,
+		Actor:     Actor(event.Context),
+		Operation: operation,
+		Changes:   diff(event.Context, sch, r.meta.Hidden, before, after),
+	}
+	if tenant, ok := Tenant(event.Context); ok && r.meta.Tenant != "" {
+		entry.Tenant = fmt.Sprint(tenant)
+	}
+	// A new statement keeps the table of Meta.Table out of the insert.
+	return r.DB.Session(&gorm.Session{NewDB: true}).Create(entry).Error
+}
+
+// RecordID returns the AuditEntry.RecordID of the record with primary key id: the key
+// formatted with fmt.Sprint, or a JSON object of the key columns e.g {"group_id":2,"user_id":1}
+// for composite keys.
+func (r *Repo[T, PK]) RecordID(id PK) (string, error) {
+	sch, err := r.schema()
+	if err != nil {
+		return "", err
+	}
+	if len(sch.PrimaryFields) < 2 || r.meta.SetPrimaryKey == nil {
+		return fmt.Sprint(id), nil
+	}
+
+	var record T
+	r.meta.SetPrimaryKey(&record, id)
+	value := reflect.ValueOf(&record).Elem()
+	key := make(map[string]any, len(sch.PrimaryFields))
+	for _, field := range sch.PrimaryFields {
+		key[field.DBName], _ = field.ValueOf(r.DB.Statement.Context, value)
+	}
+	b, err := json.Marshal(key) // Columns are sorted
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// diff returns the changes of the columns of sch between before and after, except hidden.
+// The zero columns of created and deleted records are left out.
+func diff[T any](ctx context.Context, sch *schema.Schema, hidden []string, before, after *T) map[string]Change {
+	changes := make(map[string]Change)
+	for _, field := range sch.Fields {
+		if field.DBName == "" || slices.Contains(hidden, field.DBName) {
+			continue
+		}
+
+		var change Change
+		var beforeZero, afterZero bool
+		if before != nil {
+			change.Before, beforeZero = field.ValueOf(ctx, reflect.ValueOf(before).Elem())
+		}
+		if after != nil {
+			change.After, afterZero = field.ValueOf(ctx, reflect.ValueOf(after).Elem())
+		}
+
+		switch {
+		case before == nil && afterZero, after == nil && beforeZero:
+			continue
+		case before != nil && after != nil && equal(change.Before, change.After):
+			continue
+		}
+		changes[field.DBName] = change
+	}
+	return changes
+}
+
+// equal reports whether the values of a column are equal, comparing times by instant
+// as they are read in another location than they are written.
+func equal(a, b any) bool {
+	if t, ok := a.(time.Time); ok {
+		u, ok := b.(time.Time)
+		return ok && t.Equal(u)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// History returns the audit entries of the record with primary key id, oldest first.
+// For models with Meta.Tenant, only the entries of the tenant of the context are read.
+func (r *Repo[T, PK]) History(id PK, scopes ...Scope) ([]*AuditEntry, error) {
+	if r.meta.KeyCondition == nil {
+		return nil, ErrNoPrimaryKey
+	}
+
+	return intercept(r, "History", func() ([]*AuditEntry, error) {
+		recordID, err := r.RecordID(id)
+		if err != nil {
+			return nil, err
+		}
+
+		var entries []*AuditEntry
+		db := r.DB.Session(&gorm.Session{NewDB: true}).
+			Where(&AuditEntry{Model: reflect.TypeFor[T]().Name(), RecordID: recordID}).
+			Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}})
+		if r.meta.Tenant != "" {
+			tenant, scoped, err := tenantOf(db.Statement.Context)
+			if err != nil {
+				return nil, err
+			}
+			if scoped {
+				db = db.Where(clause.Eq{Column: clause.Column{Name: "tenant"}, Value: fmt.Sprint(tenant)})
+			}
+		}
+		for _, scope := range scopes {
+			if scope != nil {
+				db = scope(db)
+			}
+		}
+		if err := db.Find(&entries).Error; err != nil {
+			return nil, err
+		}
+		return entries, nil
+	})
+}
@@ -0,0 +1,220 @@
+package repo
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestAudit(t *testing.T) {
+	db, log := hooksDB(t)
+
+	// Reads of users find the stored user, inserted audit entries are kept.
+	stored := User{ID: 1, Name: "jo", Password: "secret", RoleID: 2}
+	var entries []AuditEntry
+	err := db.Callback().Query().After("gorm:query").Register("test:rows", func(tx *gorm.DB) {
+		if user, ok := tx.Statement.Dest.(*User); ok {
+			*user, tx.RowsAffected = stored, 1
+		}
+	})
+	if err == nil {
+		err = db.Callback().Create().After("gorm:create").Register("test:entries", func(tx *gorm.DB) {
+			if entry, ok := tx.Statement.Dest.(*AuditEntry); ok {
+				if !strings.Contains(tx.Statement.SQL.String(), "INSERT INTO `audit_log`") {
+					t.Errorf("expected the entry to be inserted in audit_log, got %s", tx.Statement.SQL.String())
+				}
+				if entry.CreatedAt.IsZero() {
+					t.Error("expected the entry to be timestamped")
+				}
+				entry.CreatedAt = time.Time{}
+				entries = append(entries, *entry)
+			}
+		})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta := *userMeta
+	meta.Queries = Queries{} // No refetch
+	meta.Audit = true
+	users := New(db, &meta).WithContext(WithActor(context.Background(), "admin"))
+
+	if err := users.Create(&User{ID: 1, Name: "jo", Password: "secret", RoleID: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.Update(1, &User{Name: "joe", Password: "changed", RoleID: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Delete(1); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []AuditEntry{
+		{Model: "User", RecordID: "1", Actor: "admin", Operation: OpCreate, Changes: map[string]Change{
+			"id": {After: uint(1)}, "name": {After: "jo"}, "role_id": {After: uint(2)},
+		}},
+		{Model: "User", RecordID: "1", Actor: "admin", Operation: OpUpdate, Changes: map[string]Change{
+			"name": {Before: "jo", After: "joe"},
+		}},
+		{Model: "User", RecordID: "1", Actor: "admin", Operation: OpDelete, Changes: map[string]Change{
+			"id": {Before: uint(1)}, "name": {Before: "jo"}, "role_id": {Before: uint(2)},
+		}},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("expected entries\n%+v\ngot\n%+v", want, entries)
+	}
+	if n := strings.Count(strings.Join(log.statements, " "), "BEGIN"); n != 3 {
+		t.Errorf("expected each write to run in a transaction, got %s", strings.Join(log.statements, ", "))
+	}
+}
+
+func TestAuditMissingRecord(t *testing.T) {
+	db, log := hooksDB(t)
+
+	meta := *userMeta
+	meta.Audit = true
+	users := New(db, &meta)
+
+	// Reads find no user, so the delete matches no row.
+	if err := users.Delete(1); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"BEGIN", "SELECT", "DELETE", "COMMIT"}
+	if !slices.Equal(log.statements, want) {
+		t.Errorf("expected no audit entry, got %s", strings.Join(log.statements, ", "))
+	}
+}
+
+func TestAuditQueryWrites(t *testing.T) {
+	db, _ := hooksDB(t)
+
+	// The callbacks write the stored user, nil if there is none, and keep the audit entries.
+	var stored *User
+	var entries []AuditEntry
+	callbacks := db.Callback()
+	for name, err := range map[string]error{
+		"query": callbacks.Query().After("gorm:query").Register("test:rows", func(tx *gorm.DB) {
+			if stored == nil {
+				return
+			}
+			switch dest := tx.Statement.Dest.(type) {
+			case *User:
+				*dest, tx.RowsAffected = *stored, 1
+			case *[]*User:
+				user := *stored
+				*dest, tx.RowsAffected = []*User{&user}, 1
+			}
+		}),
+		"create": callbacks.Create().After("gorm:create").Register("test:rows", func(tx *gorm.DB) {
+			switch dest := tx.Statement.Dest.(type) {
+			case *User:
+				user := *dest
+				stored = &user
+			case *AuditEntry:
+				dest.CreatedAt = time.Time{}
+				entries = append(entries, *dest)
+			}
+		}),
+		"update": callbacks.Update().After("gorm:update").Register("test:rows", func(tx *gorm.DB) {
+			if data, ok := tx.Statement.Dest.(map[string]any); ok {
+				stored.Name = data["name"].(string)
+			}
+		}),
+		"delete": callbacks.Delete().After("gorm:delete").Register("test:rows", func(tx *gorm.DB) {
+			stored = nil
+		}),
+	} {
+		if err != nil {
+			t.Fatalf("registering %s callback: %v", name, err)
+		}
+	}
+
+	meta := *userMeta
+	meta.Queries = Queries{} // No refetch
+	meta.Audit = true
+	users := New(db, &meta)
+
+	if err := users.Upsert(&User{ID: 1, Name: "jo", RoleID: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Upsert(&User{ID: 1, Name: "joe", RoleID: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.UpdateColumn("name", "jon", "role_id = ?", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.DeleteWhere("role_id = ?", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.FirstOrCreate(&User{ID: 2, Name: "al", RoleID: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []AuditEntry{
+		{Model: "User", RecordID: "1", Operation: OpCreate, Changes: map[string]Change{
+			"id": {After: uint(1)}, "name": {After: "jo"}, "role_id": {After: uint(2)},
+		}},
+		{Model: "User", RecordID: "1", Operation: OpUpdate, Changes: map[string]Change{
+			"name": {Before: "jo", After: "joe"},
+		}},
+		{Model: "User", RecordID: "1", Operation: OpUpdate, Changes: map[string]Change{
+			"name": {Before: "joe", After: "jon"},
+		}},
+		{Model: "User", RecordID: "1", Operation: OpDelete, Changes: map[string]Change{
+			"id": {Before: uint(1)}, "name": {Before: "jon"}, "role_id": {Before: uint(2)},
+		}},
+		{Model: "User", RecordID: "2", Operation: OpCreate, Changes: map[string]Change{
+			"id": {After: uint(2)}, "name": {After: "al"}, "role_id": {After: uint(3)},
+		}},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("expected entries\n%+v\ngot\n%+v", want, entries)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	db, statements := dryRun(t)
+	users := New(db, userMeta)
+
+	if _, err := users.History(1, func(db *gorm.DB) *gorm.DB { return db.Limit(10) }); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "SELECT * FROM `audit_log` WHERE `audit_log`.`model` = ? AND `audit_log`.`record_id` = ? ORDER BY `id` LIMIT ?"
+	if got := statements(); len(got) != 1 || got[0].SQL != want {
+		t.Errorf("expected %s, got %v", want, got)
+	}
+}
+
+type Membership struct {
+	UserID  uint `gorm:"primaryKey"`
+	GroupID uint `gorm:"primaryKey"`
+	Role    string
+}
+
+type membershipKey struct{ UserID, GroupID uint }
+
+func TestRecordID(t *testing.T) {
+	db, _ := dryRun(t)
+
+	if id, err := New(db, userMeta).RecordID(1); err != nil || id != "1" {
+		t.Errorf("expected the key formatted, got %q, %v", id, err)
+	}
+
+	memberships := New(db, &Meta[Membership, membershipKey]{
+		PrimaryKey: func(m *Membership) membershipKey { return membershipKey{m.UserID, m.GroupID} },
+		SetPrimaryKey: func(m *Membership, id membershipKey) {
+			m.UserID, m.GroupID = id.UserID, id.GroupID
+		},
+	})
+	if id, err := memberships.RecordID(membershipKey{UserID: 1, GroupID: 2}); err != nil || id != `{"group_id":2,"user_id":1}` {
+		t.Errorf("expected the key columns as JSON, got %q, %v", id, err)
+	}
+}
@@ -0,0 +1,300 @@
+package repo
+
+import (
+	"context"
+	"maps"
+	"reflect"
+	"slices"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Operation is the kind of operation of a Repo that hooks run around.
+type Operation string
+
+const (
+	OpCreate Operation = "create" // Create, CreateMany, Upsert, UpsertMany and FirstOrCreate
+	OpUpdate Operation = "update" // Update, PartialUpdate, PartialUpdateWithMap, UpdateColumn and Restore
+	OpDelete Operation = "delete" // Delete, SoftDelete, ForceDelete and DeleteWhere
+	OpQuery  Operation = "query"  // Reads, run by the AroundQuery interceptors
+)
+
+// Event describes the operation of a Repo passed to hooks.
+type Event struct {
+	Operation Operation
+	Method    string          // Method of the Repo e.g "PartialUpdate"
+	Model     string          // Name of the model type e.g "User"
+	Context   context.Context // Context of the operation
+	DB        *gorm.DB        // Runs queries in the transaction of the operation, with its context
+	Key       any             // Primary key of the record updated or deleted, nil otherwise
+	Data      map[string]any  // Columns updated by PartialUpdateWithMap and UpdateColumn, that before hooks may change
+
+	where []any // Conditions of DeleteWhere and UpdateColumn, reading the records they write
+}
+
+// Hook is called with a record of an operation, a *T for the hooks of model T and
+// a *T as any for the hooks of all models. Deletes by primary key get the record as stored
+// before the delete, hidden columns excepted. record is nil for DeleteWhere, UpdateColumn,
+// and before the updates of PartialUpdateWithMap and Restore. Returning an error aborts
+// the operation.
+type Hook[R any] func(event *Event, record R) error
+
+// Interceptor runs around the reads of a Repo, calling next to run the query.
+// Returning an error without calling next aborts the read.
+type Interceptor func(event *Event, next func() error) error
+
+// HookSet holds the hooks of a model, or of all models. Write hooks run in the
+// transaction of the operation, started unless already in one, so that an error returned
+// by a hook rolls back the operation. Hooks are registered before running queries.
+//
+//	hooks.BeforeCreate(func(event *repo.Event, user *models.User) error {
+//		user.CreatedBy = auth.UserID(event.Context)
+//		return nil
+//	})
+//
+// FirstOrCreate runs the create hooks only if it creates the record.
+type HookSet[R any] struct {
+	beforeCreate, afterCreate []Hook[R]
+	beforeUpdate, afterUpdate []Hook[R]
+	beforeDelete, afterDelete []Hook[R]
+	aroundQuery               []Interceptor
+}
+
+// BeforeCreate registers fn to run before the records are inserted. fn may modify them.
+func (h *HookSet[R]) BeforeCreate(fn Hook[R]) *HookSet[R] {
+	h.beforeCreate = append(h.beforeCreate, fn)
+	return h
+}
+
+// AfterCreate registers fn to run with the records inserted.
+func (h *HookSet[R]) AfterCreate(fn Hook[R]) *HookSet[R] {
+	h.afterCreate = append(h.afterCreate, fn)
+	return h
+}
+
+// BeforeUpdate registers fn to run before the update, with the record or the partial
+// record passed to it. fn may modify it, or Event.Data for PartialUpdateWithMap.
+func (h *HookSet[R]) BeforeUpdate(fn Hook[R]) *HookSet[R] {
+	h.beforeUpdate = append(h.beforeUpdate, fn)
+	return h
+}
+
+// AfterUpdate registers fn to run with the updated record.
+func (h *HookSet[R]) AfterUpdate(fn Hook[R]) *HookSet[R] {
+	h.afterUpdate = append(h.afterUpdate, fn)
+	return h
+}
+
+// BeforeDelete registers fn to run before the delete, with Event.Key set to the
+// primary key of the record unless deleting with DeleteWhere.
+func (h *HookSet[R]) BeforeDelete(fn Hook[R]) *HookSet[R] {
+	h.beforeDelete = append(h.beforeDelete, fn)
+	return h
+}
+
+// AfterDelete registers fn to run after the delete. See BeforeDelete.
+func (h *HookSet[R]) AfterDelete(fn Hook[R]) *HookSet[R] {
+	h.afterDelete = append(h.afterDelete, fn)
+	return h
+}
+
+// AroundQuery registers fn to run around the reads: Get, GetAll, Count, GetPaginated,
+// GetPage, FindOne, FindMany, FirstOrInit, GetDeleted, FindDeleted, FindInBatches and History.
+func (h *HookSet[R]) AroundQuery(fn Interceptor) *HookSet[R] {
+	h.aroundQuery = append(h.aroundQuery, fn)
+	return h
+}
+
+// hooks returns the hooks run before and after op.
+func (h *HookSet[R]) hooks(op Operation) (before, after []Hook[R]) {
+	if h == nil {
+		return nil, nil
+	}
+	switch op {
+	case OpCreate:
+		return h.beforeCreate, h.afterCreate
+	case OpUpdate:
+		return h.beforeUpdate, h.afterUpdate
+	case OpDelete:
+		return h.beforeDelete, h.afterDelete
+	}
+	return nil, nil
+}
+
+// interceptors returns the AroundQuery interceptors.
+func (h *HookSet[R]) interceptors() []Interceptor {
+	if h == nil {
+		return nil
+	}
+	return h.aroundQuery
+}
+
+// Hooks holds the hooks of all models and, with HooksOf, those of each model.
+// The hooks of all models run first before an operation and last after it.
+type Hooks struct {
+	HookSet[any]
+
+	mu     sync.Mutex
+	models map[reflect.Type]any
+}
+
+// HooksOf returns the hooks of model T in h.
+func HooksOf[T any](h *Hooks) *HookSet[*T] {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	typ := reflect.TypeFor[T]()
+	if hooks, ok := h.models[typ]; ok {
+		return hooks.(*HookSet[*T])
+	}
+	if h.models == nil {
+		h.models = make(map[reflect.Type]any)
+	}
+	hooks := new(HookSet[*T])
+	h.models[typ] = hooks
+	return hooks
+}
+
+// WithHooks returns a copy of the repository running the hooks of all models in h and
+// those of T. Copies made with Begin keep them.
+func (r Repo[T, PK]) WithHooks(h *Hooks) *Repo[T, PK] {
+	r.hooks = HooksOf[T](h)
+	r.global = &h.HookSet
+	return &r
+}
+
+// Hooks returns the hooks of T run by the repository, registered on the Hooks of
+// WithHooks if any.
+func (r *Repo[T, PK]) Hooks() *HookSet[*T] {
+	return r.hooks
+}
+
+// event returns the event of method running op on the record with primary key key.
+func (r *Repo[T, PK]) event(op Operation, method string, key any) *Event {
+	return &Event{
+		Operation: op,
+		Method:    method,
+		Model:     reflect.TypeFor[T]().Name(),
+		Context:   r.DB.Statement.Context,
+		DB:        r.DB,
+		Key:       key,
+	}
+}
+
+// write runs fn between the hooks of event, in a transaction if there are any or if the
+// write is audited. The before hooks run with records and the after hooks with the records
+// returned by fn.
+func (r *Repo[T, PK]) write(event *Event, records []*T, fn func(r *Repo[T, PK]) ([]*T, error)) error {
+	if !r.observed(event) {
+		_, err := fn(r)
+		return err
+	}
+	globalBefore, globalAfter := r.global.hooks(event.Operation)
+	before, after := r.hooks.hooks(event.Operation)
+	audited := r.audited(event)
+
+	if event.Data != nil {
+		// Hooks may change the data, the map of the caller is left as is.
+		event.Data = maps.Clone(event.Data)
+	}
+
+	return Transaction(event.Context, r.DB, func(tx *gorm.DB) error {
+		txRepo := *r
+		txRepo.DB = tx
+		event.DB = tx
+
+		// The records as stored before the write, for the audit entries and the delete hooks.
+		var stored []*T
+		deleted := event.Operation == OpDelete && event.Key != nil
+		if audited || deleted {
+			var err error
+			if stored, err = txRepo.stored(event, records); err != nil {
+				return err
+			}
+		}
+		if deleted && stored[0] != nil {
+			// Hooks get a copy, leaving the audited values as stored.
+			record := *stored[0]
+			records = []*T{&record}
+		}
+
+		for _, record := range records {
+			if err := runHooks(event, record, globalBefore, before); err != nil {
+				return err
+			}
+		}
+		written, err := fn(&txRepo)
+		if err != nil {
+			return err
+		}
+		if deleted {
+			written = records
+		}
+		if audited {
+			if err := txRepo.auditWrite(event, stored, written); err != nil {
+				return err
+			}
+		}
+		for _, record := range written {
+			if err := runHooks(event, record, nil, after); err != nil {
+				return err
+			}
+			if err := runHooks(event, record, globalAfter, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// observed returns whether hooks run around the write of event or audit it.
+func (r *Repo[T, PK]) observed(event *Event) bool {
+	globalBefore, globalAfter := r.global.hooks(event.Operation)
+	before, after := r.hooks.hooks(event.Operation)
+	return len(globalBefore)+len(globalAfter)+len(before)+len(after) > 0 || r.audited(event)
+}
+
+// runHooks runs the hooks of all models then those of the model with record.
+func runHooks[T any](event *Event, record *T, global []Hook[any], hooks []Hook[*T]) error {
+	var value any
+	if record != nil {
+		value = record
+	}
+	for _, hook := range global {
+		if err := hook(event, value); err != nil {
+			return err
+		}
+	}
+	for _, hook := range hooks {
+		if err := hook(event, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// intercept runs the read fn of method within the AroundQuery interceptors of r, those of
+// all models outermost.
+func intercept[T any, PK comparable, R any](r *Repo[T, PK], method string, fn func() (R, error)) (R, error) {
+	interceptors := slices.Concat(r.global.interceptors(), r.hooks.interceptors())
+	if len(interceptors) == 0 {
+		return fn()
+	}
+
+	var result R
+	next := func() (err error) {
+		result, err = fn()
+		return err
+	}
+	event := r.event(OpQuery, method, nil)
+	for _, interceptor := range slices.Backward(interceptors) {
+		inner := next
+		next = func() error { return interceptor(event, inner) }
+	}
+	if err := next(); err != nil {
+		var zero R
+		return zero, err
+	}
+	return result, nil
+}
@@ -0,0 +1,285 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// hooksDB returns a database building statements without running them, logging their
+// kind along with the transaction statements. Writes run in no default transaction.
+func hooksDB(t *testing.T) (*gorm.DB, *txLog) {
+	t.Helper()
+	db, log := txDB(t)
+
+	logStatement := func(tx *gorm.DB) {
+		verb, _, _ := strings.Cut(tx.Statement.SQL.String(), " ")
+		_ = log.add(verb)
+	}
+	callbacks := db.Callback()
+	for name, err := range map[string]error{
+		"query":  callbacks.Query().After("gorm:query").Register("test:log", logStatement),
+		"create": callbacks.Create().After("gorm:create").Register("test:log", logStatement),
+		"update": callbacks.Update().After("gorm:update").Register("test:log", logStatement),
+		"delete": callbacks.Delete().After("gorm:delete").Register("test:log", logStatement),
+	} {
+		if err != nil {
+			t.Fatalf("registering %s callback: %v", name, err)
+		}
+	}
+	return db.Session(&gorm.Session{DryRun: true, SkipDefaultTransaction: true}), log
+}
+
+func TestHooks(t *testing.T) {
+	db, log := hooksDB(t)
+	meta := *userMeta
+	meta.Queries = Queries{} // No refetch
+
+	hooks := &Hooks{}
+	users := New(db, &meta).WithHooks(hooks)
+	hooks.BeforeCreate(func(event *Event, record any) error {
+		return log.add("all before " + event.Model + "." + event.Method)
+	})
+	HooksOf[User](hooks).
+		BeforeCreate(func(event *Event, user *User) error {
+			user.Name = "stamped"
+			return log.add("user before")
+		}).
+		AfterCreate(func(event *Event, user *User) error {
+			return log.add("user after " + user.Name)
+		})
+
+	user := &User{}
+	if err := users.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "stamped" {
+		t.Errorf("expected the before hook to modify the record, got %q", user.Name)
+	}
+
+	// Hooks registered on the Hooks after WithHooks are run as well.
+	HooksOf[User](hooks).BeforeUpdate(func(event *Event, user *User) error {
+		if user != nil || event.Key != uint(1) {
+			t.Errorf("expected a nil record and key 1 for map updates, got %v and %v", user, event.Key)
+		}
+		event.Data["name"] = "changed"
+		return log.add("user before update")
+	})
+	data := map[string]any{"name": "jo"}
+	if _, err := users.PartialUpdateWithMap(1, data); err != nil {
+		t.Fatal(err)
+	}
+	if data["name"] != "jo" {
+		t.Errorf("expected the map of the caller to be left as is, got %v", data)
+	}
+
+	// Repos without hooks, or operations without hooks, run no transaction.
+	if err := New(db, &meta).Create(&User{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Delete(1); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"BEGIN", "all before User.Create", "user before", "INSERT", "user after stamped", "COMMIT",
+		"BEGIN", "user before update", "UPDATE", "SELECT", "COMMIT",
+		"INSERT",
+		"DELETE",
+	}
+	if !slices.Equal(log.statements, want) {
+		t.Errorf("expected %s, got %s", strings.Join(want, ", "), strings.Join(log.statements, ", "))
+	}
+}
+
+func TestHooksAbort(t *testing.T) {
+	db, log := hooksDB(t)
+	denied := errors.New("denied")
+
+	// Reads find the user to delete.
+	err := db.Callback().Query().After("gorm:query").Register("test:rows", func(tx *gorm.DB) {
+		if user, ok := tx.Statement.Dest.(*User); ok {
+			*user, tx.RowsAffected = User{ID: 1, Name: "jo"}, 1
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hooks := &Hooks{}
+	users := New(db, userMeta).WithHooks(hooks)
+	hooks.AfterDelete(func(event *Event, record any) error {
+		if user, ok := record.(*User); !ok || user.Name != "jo" || event.Key != uint(1) {
+			t.Errorf("expected the deleted user and key 1 for deletes, got %v and %v", record, event.Key)
+		}
+		return denied
+	})
+
+	if err := users.Delete(1); !errors.Is(err, denied) {
+		t.Errorf("expected the hook error, got %v", err)
+	}
+
+	// Within a transaction the operation is rolled back to a savepoint.
+	err = Transaction(context.Background(), db, func(tx *gorm.DB) error {
+		if err := New(tx, userMeta).WithHooks(hooks).Delete(1); !errors.Is(err, denied) {
+			t.Errorf("expected the hook error, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"BEGIN", "SELECT", "DELETE", "ROLLBACK",
+		"BEGIN", "SAVEPOINT", "SELECT", "DELETE", "ROLLBACK TO SAVEPOINT", "COMMIT",
+	}
+	if !slices.Equal(log.statements, want) {
+		t.Errorf("expected %s, got %s", strings.Join(want, ", "), strings.Join(log.statements, ", "))
+	}
+}
+
+func TestHooksFirstOrCreate(t *testing.T) {
+	db, log := hooksDB(t)
+
+	// Reads find a user once found is set.
+	var found bool
+	err := db.Callback().Query().After("gorm:query").Register("test:rows", func(tx *gorm.DB) {
+		if user, ok := tx.Statement.Dest.(*User); ok && found {
+			*user, tx.RowsAffected = User{ID: 1, Name: "jo"}, 1
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta := *userMeta
+	meta.Queries = Queries{} // No refetch
+	hooks := &Hooks{}
+	users := New(db, &meta).WithHooks(hooks)
+	HooksOf[User](hooks).BeforeCreate(func(event *Event, user *User) error {
+		if user.Name != "jo" {
+			t.Errorf("expected the record to create, got %+v", user)
+		}
+		return log.add("before " + event.Method)
+	})
+
+	if err := users.FirstOrCreate(&User{Name: "jo"}); err != nil {
+		t.Fatal(err)
+	}
+	found = true
+	if err := users.FirstOrCreate(&User{Name: "jo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"BEGIN", "SELECT", "SAVEPOINT", "before FirstOrCreate", "INSERT", "COMMIT",
+		"BEGIN", "SELECT", "SELECT", "COMMIT",
+	}
+	if !slices.Equal(log.statements, want) {
+		t.Errorf("expected %s, got %s", strings.Join(want, ", "), strings.Join(log.statements, ", "))
+	}
+}
+
+func TestHooksUpdateColumn(t *testing.T) {
+	db, log := hooksDB(t)
+
+	hooks := &Hooks{}
+	users := New(db, userMeta).WithHooks(hooks)
+	hooks.BeforeUpdate(func(event *Event, record any) error {
+		if record != nil || event.Key != nil || event.Data["name"] != "jo" {
+			t.Errorf("expected a nil record, no key and the column in the data, got %v, %v and %v", record, event.Key, event.Data)
+		}
+		event.Data["name"] = "Jo"
+		return log.add("before " + event.Method)
+	})
+
+	if err := users.UpdateColumn("name", "jo", "role_id = ?", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"BEGIN", "before UpdateColumn", "UPDATE", "COMMIT"}
+	if !slices.Equal(log.statements, want) {
+		t.Errorf("expected %s, got %s", strings.Join(want, ", "), strings.Join(log.statements, ", "))
+	}
+}
+
+func TestHooksRestore(t *testing.T) {
+	db, log := hooksDB(t)
+
+	// Updates match a row, reads find the restored invoice.
+	err := db.Callback().Update().After("gorm:update").Register("test:rows", func(tx *gorm.DB) {
+		tx.RowsAffected = 1
+	})
+	if err == nil {
+		err = db.Callback().Query().After("gorm:query").Register("test:rows", func(tx *gorm.DB) {
+			if invoice, ok := tx.Statement.Dest.(*Invoice); ok {
+				*invoice, tx.RowsAffected = Invoice{ID: 1, Total: 10}, 1
+			}
+		})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hooks := &Hooks{}
+	invoices := New(db, &Meta[Invoice, uint]{
+		DeletedAt:    "deleted_at",
+		PrimaryKey:   func(i *Invoice) uint { return i.ID },
+		KeyCondition: func(keys ...uint) clause.Expression { return clause.Eq{Column: clause.PrimaryColumn, Value: keys[0]} },
+	}).WithHooks(hooks)
+	HooksOf[Invoice](hooks).AfterUpdate(func(event *Event, invoice *Invoice) error {
+		if event.Method != "Restore" || invoice == nil || invoice.Total != 10 {
+			t.Errorf("expected the restored invoice, got %s with %v", event.Method, invoice)
+		}
+		return log.add("invoice restored")
+	})
+
+	if err := invoices.Restore(1); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"BEGIN", "UPDATE", "SELECT", "invoice restored", "COMMIT"}
+	if !slices.Equal(log.statements, want) {
+		t.Errorf("expected %s, got %s", strings.Join(want, ", "), strings.Join(log.statements, ", "))
+	}
+}
+
+func TestAroundQuery(t *testing.T) {
+	db, log := hooksDB(t)
+	denied := errors.New("denied")
+
+	hooks := &Hooks{}
+	users := New(db, userMeta).WithHooks(hooks)
+	hooks.AroundQuery(func(event *Event, next func() error) error {
+		_ = log.add("all " + event.Method)
+		return next()
+	})
+	HooksOf[User](hooks).AroundQuery(func(event *Event, next func() error) error {
+		if event.Operation != OpQuery || event.Model != "User" {
+			t.Errorf("expected a query of User, got %+v", event)
+		}
+		_ = log.add("user " + event.Method)
+		if event.Method == "Count" {
+			return denied
+		}
+		return next()
+	})
+
+	if _, err := users.FindMany(); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := users.Count(); !errors.Is(err, denied) || count != 0 {
+		t.Errorf("expected the interceptor error, got %d and %v", count, err)
+	}
+
+	want := []string{"all FindMany", "user FindMany", "SELECT", "all Count", "user Count"}
+	if !slices.Equal(log.statements, want) {
+		t.Errorf("expected %s, got %s", strings.Join(want, ", "), strings.Join(log.statements, ", "))
+	}
+}
@@ -0,0 +1,87 @@
+package repo
+
+import (
+	"errors"
+	"iter"
+
+	"gorm.io/gorm"
+)
+
+// DefaultBatchSize is the number of records read per query by Iter.
+const DefaultBatchSize = 500
+
+// errStop stops FindInBatches when the loop over an iterator breaks.
+var errStop = errors.New("repo: stop iteration")
+
+// FindInBatches calls fn with the records matching the query, batchSize records at a time
+// in the order of Meta.Keyset. Batches are numbered from 1 and the relations are preloaded
+// for each batch like FindMany does. Stops at the first error returned by fn.
+// Scopes must not set an order or limit.
+func (r *Repo[T, PK]) FindInBatches(batchSize int, fn func(records []*T, batch int) error, scopes ...Scope) error {
+	_, err := intercept(r, "FindInBatches", func() (struct{}, error) {
+		return struct{}{}, r.findInBatches(batchSize, fn, scopes)
+	})
+	return err
+}
+
+func (r *Repo[T, PK]) findInBatches(batchSize int, fn func(records []*T, batch int) error, scopes []Scope) error {
+	if batchSize < 1 {
+		batchSize = DefaultBatchSize
+	}
+
+	columns, err := r.keyset()
+	if err != nil {
+		return err
+	}
+
+	// A new session lets each batch add its condition to the query.
+	db := r.query(r.shouldPreload(r.meta.Queries.FindMany.PreloadAll), scopes).Session(&gorm.Session{})
+
+	var after []any
+	for batch := 1; ; batch++ {
+		query := db
+		if after != nil {
+			query = query.Where(keysetCondition(columns, after, false))
+		}
+
+		var records []*T
+		if err := keysetOrder(query, columns, false).Limit(batchSize).Find(&records).Error; err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		if err := fn(records, batch); err != nil {
+			return err
+		}
+		if len(records) < batchSize {
+			return nil
+		}
+		after = keysetValues(db.Statement.Context, columns, records[len(records)-1])
+	}
+}
+
+// Each returns an iterator over the records matching the query, read batchSize records
+// at a time with FindInBatches. A query error is yielded last with a nil record.
+func (r *Repo[T, PK]) Each(batchSize int, scopes ...Scope) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		err := r.FindInBatches(batchSize, func(records []*T, _ int) error {
+			for _, record := range records {
+				if !yield(record, nil) {
+					return errStop
+				}
+			}
+			return nil
+		}, scopes...)
+
+		if err != nil && !errors.Is(err, errStop) {
+			yield(nil, err)
+		}
+	}
+}
+
+// Iter returns an iterator over the records matching the query, read DefaultBatchSize
+// records at a time. See Each.
+func (r *Repo[T, PK]) Iter(scopes ...Scope) iter.Seq2[*T, error] {
+	return r.Each(DefaultBatchSize, scopes...)
+}
@@ -0,0 +1,97 @@
+package repo
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// batches makes the queries of db return the batches of invoices in turn.
+func batches(t *testing.T, db *gorm.DB, batches ...[]*Invoice) {
+	t.Helper()
+	err := db.Callback().Query().After("gorm:query").Register("test:batches", func(tx *gorm.DB) {
+		dest, ok := tx.Statement.Dest.(*[]*Invoice)
+		if !ok || len(batches) == 0 {
+			return
+		}
+		*dest, batches = batches[0], batches[1:]
+		tx.RowsAffected = int64(len(*dest))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindInBatches(t *testing.T) {
+	db, statements := dryRun(t)
+	batches(t, db, []*Invoice{{ID: 1}, {ID: 2}}, []*Invoice{{ID: 3}})
+	invoices := New(db, &Meta[Invoice, uint]{DeletedAt: "deleted_at"})
+
+	var sizes []int
+	err := invoices.FindInBatches(2, func(records []*Invoice, batch int) error {
+		if batch != len(sizes)+1 {
+			t.Errorf("expected batch %d, got %d", len(sizes)+1, batch)
+		}
+		sizes = append(sizes, len(records))
+		return nil
+	}, func(db *gorm.DB) *gorm.DB { return db.Where("total > ?", 10) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(sizes, []int{2, 1}) {
+		t.Errorf("expected batches of 2 and 1 records, got %v", sizes)
+	}
+
+	var got []string
+	for _, stmt := range statements() {
+		got = append(got, stmt.SQL)
+	}
+	want := []string{
+		"SELECT * FROM `invoices` WHERE total > ? AND `invoices`.`deleted_at` IS NULL ORDER BY `invoices`.`id` LIMIT ?",
+		"SELECT * FROM `invoices` WHERE total > ? AND `invoices`.`id` > ? AND `invoices`.`deleted_at` IS NULL ORDER BY `invoices`.`id` LIMIT ?",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected statements\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	stop := errors.New("stop")
+	db, _ = dryRun(t)
+	batches(t, db, []*Invoice{{ID: 1}})
+	invoices = New(db, &Meta[Invoice, uint]{})
+	if err := invoices.FindInBatches(1, func([]*Invoice, int) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("expected the error returned by fn, got %v", err)
+	}
+}
+
+func TestIter(t *testing.T) {
+	db, statements := dryRun(t)
+	batches(t, db, []*Invoice{{ID: 1}, {ID: 2}}, []*Invoice{{ID: 3}, {ID: 4}})
+	invoices := New(db, &Meta[Invoice, uint]{})
+
+	var ids []uint
+	for invoice, err := range invoices.Each(2) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, invoice.ID)
+		if invoice.ID == 3 {
+			break
+		}
+	}
+	if !slices.Equal(ids, []uint{1, 2, 3}) {
+		t.Errorf("expected invoices 1 to 3, got %v", ids)
+	}
+	if n := len(statements()); n != 2 {
+		t.Errorf("expected breaking the loop to stop reading batches, got %d queries", n)
+	}
+
+	logs := New(db, &Meta[struct{ Message string }, NoKey]{Table: "logs"})
+	for record, err := range logs.Iter() {
+		if record != nil || !errors.Is(err, ErrNoPrimaryKey) {
+			t.Errorf("expected ErrNoPrimaryKey, got %v, %v", record, err)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package repo
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrInvalidCursor is returned by GetPage for cursors it did not return.
+var ErrInvalidCursor = errors.New("repo: invalid cursor")
+
+// ErrPageOrder is returned by GetPage for scopes ordering the query, as the pages follow
+// the order of Meta.Keyset only.
+var ErrPageOrder = errors.New("repo: GetPage is ordered by the keyset, not by its scopes")
+
+// CursorResults is a page of records read with GetPage.
+type CursorResults[T any] struct {
+	Limit      int    `json:"limit"`
+	Count      *int64 `json:"count,omitempty"` // Records matching the query, only counted with WithCount
+	HasNext    bool   `json:"has_next"`
+	HasPrev    bool   `json:"has_prev"`
+	NextCursor string `json:"next_cursor,omitempty"` // Cursor of the next page, empty on the last page
+	PrevCursor string `json:"prev_cursor,omitempty"` // Cursor of the previous page, empty on the first page
+	Results    []T    `json:"results"`
+}
+
+// cursor is the position of a page boundary, encoded in the opaque cursor strings.
+type cursor struct {
+	Values   []json.RawMessage `json:"v"` // Values of the keyset columns of the boundary record
+	Backward bool              `json:"b"` // Whether the page is before the boundary record
+}
+
+// keysetColumn is a column ordering the pages of GetPage.
+type keysetColumn struct {
+	field *schema.Field
+	desc  bool
+}
+
+// WithCount returns a copy of the repository counting the records matching the query in GetPage.
+func (r Repo[T, PK]) WithCount() *Repo[T, PK] {
+	r.withCount = true
+	return &r
+}
+
+// GetPage returns up to limit records following the position of cursor in the order
+// of Meta.Keyset, or the first page for an empty cursor. Unlike GetPaginated the records
+// are not skipped with OFFSET and are only counted with WithCount.
+// Pass the NextCursor or PrevCursor of the results to read the next or previous page.
+// Scopes ordering the query fail with ErrPageOrder.
+func (r *Repo[T, PK]) GetPage(cursorValue string, limit int, scopes ...Scope) (*CursorResults[*T], error) {
+	return intercept(r, "GetPage", func() (*CursorResults[*T], error) {
+		if limit < 1 {
+			limit = 1
+		}
+
+		columns, err := r.keyset()
+		if err != nil {
+			return nil, err
+		}
+
+		var values []any
+		var backward bool
+		if cursorValue != "" {
+			if values, backward, err = decodeCursor(cursorValue, columns); err != nil {
+				return nil, err
+			}
+		}
+
+		db := r.query(r.shouldPreload(r.meta.Queries.GetPage.PreloadAll), scopes)
+		if _, ordered := db.Statement.Clauses["ORDER BY"]; ordered {
+			return nil, ErrPageOrder
+		}
+
+		page := &CursorResults[*T]{Limit: limit}
+		if r.withCount {
+			var count int64
+			if err := db.Model(new(T)).Count(&count).Error; err != nil {
+				return nil, err
+			}
+			page.Count = &count
+		}
+
+		if values != nil {
+			db = db.Where(keysetCondition(columns, values, backward))
+		}
+		db = keysetOrder(db, columns, backward)
+
+		// Reading one more record tells whether there is another page.
+		var results []*T
+		if err := db.Limit(limit + 1).Find(&results).Error; err != nil {
+			return nil, err
+		}
+		more := len(results) > limit
+		if more {
+			results = results[:limit]
+		}
+		if backward {
+			slices.Reverse(results)
+		}
+
+		page.Results = results
+		page.HasNext = more || backward
+		page.HasPrev = (more && backward) || (values != nil && !backward)
+		if len(results) > 0 {
+			if page.HasNext {
+				if page.NextCursor, err = encodeCursor(db, columns, results[len(results)-1], false); err != nil {
+					return nil, err
+				}
+			}
+			if page.HasPrev {
+				if page.PrevCursor, err = encodeCursor(db, columns, results[0], true); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return page, nil
+	})
+}
+
+// keyset returns the columns ordering GetPage: Meta.Keyset followed by the primary key
+// columns it lacks, so that the order is unique.
+func (r *Repo[T, PK]) keyset() ([]keysetColumn, error) {
+	sch, err := r.schema()
+	if err != nil {
+		return nil, err
+	}
+
+	var columns []keysetColumn
+	desc := false
+	for _, name := range r.meta.Keyset {
+		name, desc = strings.CutPrefix(name, "-")
+		field := sch.LookUpField(name)
+		if field == nil || field.DBName == "" {
+			return nil, fmt.Errorf("repo: unknown keyset column %q", name)
+		}
+		columns = append(columns, keysetColumn{field: field, desc: desc})
+	}
+
+	for _, field := range sch.PrimaryFields {
+		if !slices.ContainsFunc(columns, func(c keysetColumn) bool { return c.field == field }) {
+			columns = append(columns, keysetColumn{field: field, desc: desc})
+		}
+	}
+	if len(columns) == 0 {
+		return nil, ErrNoPrimaryKey
+	}
+	return columns, nil
+}
+
+// keysetCondition returns the condition matching the records after values of columns
+// in their order, or before them if backward:
+//
+//	a > ? OR (a = ? AND b > ?) OR (a = ? AND b = ? AND c > ?)
+func keysetCondition(columns []keysetColumn, values []any, backward bool) clause.Expression {
+	ors := make([]clause.Expression, len(columns))
+	for i, column := range columns {
+		ands := make([]clause.Expression, 0, i+1)
+		for j := range i {
+			ands = append(ands, clause.Eq{Column: columns[j].column(), Value: values[j]})
+		}
+		if column.desc != backward {
+			ands = append(ands, clause.Lt{Column: column.column(), Value: values[i]})
+		} else {
+			ands = append(ands, clause.Gt{Column: column.column(), Value: values[i]})
+		}
+		ors[i] = clause.And(ands...)
+	}
+	return clause.Or(ors...)
+}
+
+// keysetOrder orders db by columns, in reverse if backward.
+func keysetOrder(db *gorm.DB, columns []keysetColumn, backward bool) *gorm.DB {
+	for _, column := range columns {
+		db = db.Order(clause.OrderByColumn{Column: column.column(), Desc: column.desc != backward})
+	}
+	return db
+}
+
+// keysetValues returns the values of columns in record.
+func keysetValues[T any](ctx context.Context, columns []keysetColumn, record *T) []any {
+	value := reflect.ValueOf(record).Elem()
+	values := make([]any, len(columns))
+	for i, column := range columns {
+		values[i], _ = column.field.ValueOf(ctx, value)
+	}
+	return values
+}
+
+func (c keysetColumn) column() clause.Column {
+	return clause.Column{Table: clause.CurrentTable, Name: c.field.DBName}
+}
+
+// encodeCursor returns the cursor of the page after record, or before it if backward.
+func encodeCursor[T any](db *gorm.DB, columns []keysetColumn, record *T, backward bool) (string, error) {
+	pos := cursor{Values: make([]json.RawMessage, len(columns)), Backward: backward}
+	for i, value := range keysetValues(db.Statement.Context, columns, record) {
+		b, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		pos.Values[i] = b
+	}
+
+	b, err := json.Marshal(pos)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodeCursor decodes a cursor returned by GetPage into values of columns.
+func decodeCursor(value string, columns []keysetColumn) (values []any, backward bool, err error) {
+	var pos cursor
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, false, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(b, &pos); err != nil || len(pos.Values) != len(columns) {
+		return nil, false, ErrInvalidCursor
+	}
+
+	values = make([]any, len(columns))
+	for i, column := range columns {
+		value := reflect.New(column.field.FieldType)
+		if err := json.Unmarshal(pos.Values[i], value.Interface()); err != nil {
+			return nil, false, ErrInvalidCursor
+		}
+		values[i] = value.Elem().Interface()
+	}
+	return values, pos.Backward, nil
+}
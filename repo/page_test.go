@@ -0,0 +1,93 @@
+package repo
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestGetPage(t *testing.T) {
+	db, statements := dryRun(t)
+
+	// Queries return the rows of invoices after the cursor, like a table holding totals 50 to 10.
+	rows := []*Invoice{{ID: 5, Total: 50}, {ID: 4, Total: 40}, {ID: 3, Total: 30}, {ID: 2, Total: 20}, {ID: 1, Total: 10}}
+	err := db.Callback().Query().After("gorm:query").Register("test:rows", func(tx *gorm.DB) {
+		if dest, ok := tx.Statement.Dest.(*[]*Invoice); ok {
+			*dest = rows
+			tx.RowsAffected = int64(len(rows))
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invoices := New(db, &Meta[Invoice, uint]{Keyset: []string{"-total"}, DeletedAt: "deleted_at"})
+
+	rows = rows[:3] // limit + 1
+	first, err := invoices.WithCount().GetPage("", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Results) != 2 || !first.HasNext || first.HasPrev || first.NextCursor == "" || first.PrevCursor != "" || first.Count == nil {
+		t.Fatalf("unexpected first page %+v", first)
+	}
+
+	rows = []*Invoice{{ID: 3, Total: 30}, {ID: 2, Total: 20}} // last page
+	second, err := invoices.GetPage(first.NextCursor, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.HasNext || !second.HasPrev || second.NextCursor != "" || second.Count != nil {
+		t.Fatalf("unexpected second page %+v", second)
+	}
+
+	rows = []*Invoice{{ID: 4, Total: 40}, {ID: 5, Total: 50}} // read backward
+	prev, err := invoices.GetPage(second.PrevCursor, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := []uint{prev.Results[0].ID, prev.Results[1].ID}; !slices.Equal(ids, []uint{5, 4}) {
+		t.Errorf("expected the previous page in order, got %v", ids)
+	}
+	if !prev.HasNext || prev.HasPrev {
+		t.Errorf("unexpected previous page %+v", prev)
+	}
+
+	var got []string
+	for _, stmt := range statements() {
+		got = append(got, stmt.SQL)
+	}
+	want := []string{
+		"SELECT count(*) FROM `invoices` WHERE `invoices`.`deleted_at` IS NULL",
+		"SELECT * FROM `invoices` WHERE `invoices`.`deleted_at` IS NULL ORDER BY `invoices`.`total` DESC,`invoices`.`id` DESC LIMIT ?",
+		"SELECT * FROM `invoices` WHERE (`invoices`.`total` < ? OR (`invoices`.`total` = ? AND `invoices`.`id` < ?)) AND `invoices`.`deleted_at` IS NULL ORDER BY `invoices`.`total` DESC,`invoices`.`id` DESC LIMIT ?",
+		"SELECT * FROM `invoices` WHERE (`invoices`.`total` > ? OR (`invoices`.`total` = ? AND `invoices`.`id` > ?)) AND `invoices`.`deleted_at` IS NULL ORDER BY `invoices`.`total`,`invoices`.`id` LIMIT ?",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected statements\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestGetPageErrors(t *testing.T) {
+	db, _ := dryRun(t)
+	invoices := New(db, &Meta[Invoice, uint]{})
+
+	for _, cursor := range []string{"not a cursor", "e30", "eyJ2IjpbIjEiXX0"} { // {}, {"v":["1"]}
+		if _, err := invoices.GetPage(cursor, 10); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("expected ErrInvalidCursor for %q, got %v", cursor, err)
+		}
+	}
+
+	order := func(db *gorm.DB) *gorm.DB { return db.Order("id") }
+	if _, err := invoices.GetPage("", 10, order); !errors.Is(err, ErrPageOrder) {
+		t.Errorf("expected ErrPageOrder for an ordering scope, got %v", err)
+	}
+
+	unknown := New(db, &Meta[Invoice, uint]{Keyset: []string{"missing"}})
+	if _, err := unknown.GetPage("", 10); err == nil {
+		t.Errorf("expected an error for an unknown keyset column")
+	}
+}
@@ -0,0 +1,225 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrUnknownField is wrapped by the QueryError of query parameters naming no allowed field.
+var ErrUnknownField = errors.New("unknown field")
+
+// Query parameters of ParseQuery that are not filters.
+const (
+	SortParam     = "sort"      // Comma separated fields, prefixed with "-" for a descending order e.g "-created_at,name"
+	PageParam     = "page"      // Page number starting at 1
+	PageSizeParam = "page_size" // Records per page, DefaultPageSize by default
+)
+
+// DefaultPageSize is the page size of ParseQuery when the page_size parameter is missing.
+const DefaultPageSize = 20
+
+// QueryKind is the kind of values of a QueryField, to convert query values to.
+type QueryKind int
+
+const (
+	QueryString QueryKind = iota // Values are passed as strings e.g text, dates and enums
+	QueryInt
+	QueryUint
+	QueryFloat
+	QueryBool
+)
+
+// QueryField is a column filtered and sorted on by ParseQuery.
+type QueryField struct {
+	Column string
+	Kind   QueryKind
+}
+
+// QueryError is returned by ParseQuery for an invalid query parameter.
+type QueryError struct {
+	Param string // Query parameter e.g "age__gt"
+	Err   error
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("invalid query parameter %q: %v", e.Param, e.Err)
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// ParseQuery converts query parameters into scopes filtering, sorting and paginating the
+// records of a model whose fields are allowed by name e.g
+//
+//	?age__gt=18&name__ilike=jo&sort=-created_at,name&page=2&page_size=50
+//
+// Filters are written field__operator=value, or field=value for eq. The operators are
+// eq, ne, gt, gte, lt, lte, in (comma separated values), ilike, isnull (true or false)
+// and between (two comma separated values). A field repeated with several operators
+// adds a condition for each. Parameters naming no field in fields are rejected with a
+// *QueryError wrapping ErrUnknownField, so column names never reach SQL from callers.
+func ParseQuery(query url.Values, fields map[string]QueryField) ([]Scope, error) {
+	var scopes []Scope
+
+	// Sorted parameters keep the generated SQL stable.
+	params := make([]string, 0, len(query))
+	for param := range query {
+		params = append(params, param)
+	}
+	slices.Sort(params)
+
+	for _, param := range params {
+		switch param {
+		case SortParam, PageParam, PageSizeParam:
+			continue
+		}
+
+		name, operator, _ := strings.Cut(param, "__")
+		field, ok := fields[name]
+		if !ok {
+			return nil, &QueryError{Param: param, Err: ErrUnknownField}
+		}
+		for _, value := range query[param] {
+			expr, err := filter(field, operator, value)
+			if err != nil {
+				return nil, &QueryError{Param: param, Err: err}
+			}
+			scopes = append(scopes, func(db *gorm.DB) *gorm.DB { return db.Where(expr) })
+		}
+	}
+
+	if sort := query.Get(SortParam); sort != "" {
+		var columns []clause.OrderByColumn
+		for name := range strings.SplitSeq(sort, ",") {
+			name, desc := strings.CutPrefix(strings.TrimSpace(name), "-")
+			field, ok := fields[name]
+			if !ok {
+				return nil, &QueryError{Param: SortParam, Err: fmt.Errorf("%w %q", ErrUnknownField, name)}
+			}
+			columns = append(columns, clause.OrderByColumn{Column: queryColumn(field), Desc: desc})
+		}
+		scopes = append(scopes, func(db *gorm.DB) *gorm.DB {
+			return db.Order(clause.OrderBy{Columns: columns})
+		})
+	}
+
+	if query.Has(PageParam) || query.Has(PageSizeParam) {
+		page, err := positive(query, PageParam, 1)
+		if err != nil {
+			return nil, err
+		}
+		pageSize, err := positive(query, PageSizeParam, DefaultPageSize)
+		if err != nil {
+			return nil, err
+		}
+		scopes = append(scopes, func(db *gorm.DB) *gorm.DB {
+			return db.Offset((page - 1) * pageSize).Limit(pageSize)
+		})
+	}
+	return scopes, nil
+}
+
+// filter returns the condition of operator on field with the query value.
+func filter(field QueryField, operator, value string) (clause.Expression, error) {
+	col := queryColumn(field)
+
+	switch operator {
+	case "isnull":
+		isNull, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("expected true or false, got %q", value)
+		}
+		if isNull {
+			return clause.Eq{Column: col, Value: nil}, nil
+		}
+		return clause.Neq{Column: col, Value: nil}, nil
+	case "ilike":
+		if field.Kind != QueryString {
+			return nil, errors.New("ilike only applies to text fields")
+		}
+		return clause.Expr{SQL: "? ILIKE ?", Vars: []any{col, "%" + value + "%"}}, nil
+	case "in", "between":
+		values := strings.Split(value, ",")
+		if operator == "between" && len(values) != 2 {
+			return nil, fmt.Errorf("expected two comma separated values, got %q", value)
+		}
+		vars := make([]any, len(values))
+		for i, v := range values {
+			converted, err := convert(field, v)
+			if err != nil {
+				return nil, err
+			}
+			vars[i] = converted
+		}
+		if operator == "in" {
+			return clause.IN{Column: col, Values: vars}, nil
+		}
+		return clause.Expr{SQL: "? BETWEEN ? AND ?", Vars: []any{col, vars[0], vars[1]}}, nil
+	}
+
+	converted, err := convert(field, value)
+	if err != nil {
+		return nil, err
+	}
+	switch operator {
+	case "", "eq":
+		return clause.Eq{Column: col, Value: converted}, nil
+	case "ne":
+		return clause.Neq{Column: col, Value: converted}, nil
+	case "gt":
+		return clause.Gt{Column: col, Value: converted}, nil
+	case "gte":
+		return clause.Gte{Column: col, Value: converted}, nil
+	case "lt":
+		return clause.Lt{Column: col, Value: converted}, nil
+	case "lte":
+		return clause.Lte{Column: col, Value: converted}, nil
+	}
+	return nil, fmt.Errorf("unknown operator %q", operator)
+}
+
+// convert converts a query value to the kind of field.
+func convert(field QueryField, value string) (any, error) {
+	var converted any
+	var err error
+	switch field.Kind {
+	case QueryInt:
+		converted, err = strconv.ParseInt(value, 10, 64)
+	case QueryUint:
+		converted, err = strconv.ParseUint(value, 10, 64)
+	case QueryFloat:
+		converted, err = strconv.ParseFloat(value, 64)
+	case QueryBool:
+		converted, err = strconv.ParseBool(value)
+	default:
+		return value, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q", value)
+	}
+	return converted, nil
+}
+
+func queryColumn(field QueryField) clause.Column {
+	return clause.Column{Table: clause.CurrentTable, Name: field.Column}
+}
+
+// positive returns the positive integer query parameter param, or defaultValue if missing.
+func positive(query url.Values, param string, defaultValue int) (int, error) {
+	if !query.Has(param) {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(query.Get(param))
+	if err != nil || n < 1 {
+		return 0, &QueryError{Param: param, Err: fmt.Errorf("expected a positive integer, got %q", query.Get(param))}
+	}
+	return n, nil
+}
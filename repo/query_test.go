@@ -0,0 +1,84 @@
+package repo
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+var invoiceQueryFields = map[string]QueryField{
+	"id":    {Column: "id", Kind: QueryUint},
+	"total": {Column: "total", Kind: QueryInt},
+	"note":  {Column: "note"},
+}
+
+func TestParseQuery(t *testing.T) {
+	db, statements := dryRun(t)
+	invoices := New(db, &Meta[Invoice, uint]{})
+
+	query, err := url.ParseQuery("total__gt=10&total__lte=100&id__in=1,2&note__ilike=paid&note__isnull=false&id=3&sort=-total,id&page=3&page_size=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scopes, err := ParseQuery(query, invoiceQueryFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := invoices.FindMany(scopes...); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "SELECT * FROM `invoices` WHERE `invoices`.`id` = ? AND `invoices`.`id` IN (?,?) AND `invoices`.`note` ILIKE ? AND `invoices`.`note` IS NOT NULL AND `invoices`.`total` > ? AND `invoices`.`total` <= ? AND `invoices`.`deleted_at` IS NULL ORDER BY `invoices`.`total` DESC,`invoices`.`id` LIMIT ? OFFSET ?"
+	if got := statements()[0].SQL; got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for query, param := range map[string]string{
+		"password=x":          "password",
+		"total__like=1":       "total__like",
+		"total=ten":           "total",
+		"total__between=1":    "total__between",
+		"total__ilike=1":      "total__ilike",
+		"note__isnull=maybe":  "note__isnull",
+		"sort=-password":      "sort",
+		"page=0":              "page",
+		"page=1&page_size=-5": "page_size",
+	} {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = ParseQuery(values, invoiceQueryFields)
+		var queryErr *QueryError
+		if !errors.As(err, &queryErr) || queryErr.Param != param {
+			t.Errorf("%s: expected a QueryError for %s, got %v", query, param, err)
+		}
+		if strings.Contains(query, "password") && !errors.Is(err, ErrUnknownField) {
+			t.Errorf("%s: expected ErrUnknownField, got %v", query, err)
+		}
+	}
+}
+
+func TestParseQueryValues(t *testing.T) {
+	values := url.Values{"total__between": {"1,9"}, "id": {"4"}}
+	scopes, err := ParseQuery(values, invoiceQueryFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, statements := dryRun(t)
+	if _, err := New(db, &Meta[Invoice, uint]{}).FindMany(scopes...); err != nil {
+		t.Fatal(err)
+	}
+	stmt := statements()[0]
+	if !strings.Contains(stmt.SQL, "`invoices`.`total` BETWEEN ? AND ?") {
+		t.Errorf("expected a BETWEEN condition, got %s", stmt.SQL)
+	}
+	if len(scopes) != 2 {
+		t.Errorf("expected a scope per filter, got %d", len(scopes))
+	}
+}
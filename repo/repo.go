@@ -0,0 +1,1016 @@
+// Package repo implements the GORM repository behind the services generated by apigen.
+//
+// The generated services are thin typed wrappers over a Repo and the Meta describing
+// their model, so the CRUD, pagination and preload logic lives here once:
+//
+//	var userMeta = &repo.Meta[models.User, uint]{
+//		Preloads:     []string{"Role"},
+//		Omit:         []string{"Role"},
+//		PrimaryKey:   func(u *models.User) uint { return u.ID },
+//		KeyCondition: ...,
+//	}
+//
+//	users := repo.New(db, userMeta)
+//	user, err := users.PreloadAll(false).Get(1)
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrNoPrimaryKey is returned by the methods taking a primary key for models without one.
+var ErrNoPrimaryKey = errors.New("repo: model has no primary key")
+
+// ErrNoSoftDelete is returned by the soft delete methods for models without a gorm.DeletedAt field.
+var ErrNoSoftDelete = errors.New("repo: model has no gorm.DeletedAt field")
+
+// ErrNoConflictColumns is returned by the upsert methods for models without
+// conflict columns or a primary key.
+var ErrNoConflictColumns = errors.New("repo: model has no conflict columns to upsert on")
+
+// ErrStaleObject is returned by the updates of models with a version column when the
+// record was updated since the version passed was read.
+var ErrStaleObject = errors.New("repo: record was modified since it was read")
+
+// Scope modifies a query before it runs, like the functions passed to gorm.DB.Scopes.
+type Scope = func(db *gorm.DB) *gorm.DB
+
+// NoKey is the primary key type of models without a usable primary key.
+type NoKey struct{}
+
+// QuerySettings configures the preloads of a method.
+type QuerySettings struct {
+	PreloadAll        bool // Preload the relations of Meta.Preloads by default
+	RefetchAfterWrite bool // Refetch written records to load their relations
+}
+
+// Queries holds the settings of each method, see config.QuerySet.
+type Queries struct {
+	Get                  QuerySettings
+	GetAll               QuerySettings
+	GetPaginated         QuerySettings
+	GetPage              QuerySettings
+	FindOne              QuerySettings
+	FindMany             QuerySettings
+	Create               QuerySettings
+	CreateMany           QuerySettings
+	Update               QuerySettings
+	PartialUpdate        QuerySettings
+	PartialUpdateWithMap QuerySettings
+	Upsert               QuerySettings
+	UpsertMany           QuerySettings
+	FirstOrCreate        QuerySettings
+	FirstOrInit          QuerySettings
+}
+
+// Meta describes the model T to a Repo. It is generated for each model and
+// must not be modified once used.
+type Meta[T any, PK comparable] struct {
+	Table    string   // Table of the model when set with apigen:table
+	Preloads []string // Relations preloaded e.g "Role" or "Role.Permissions"
+	Omit     []string // Associations omitted on writes
+	Hidden   []string // Columns never read and not overwritten by Update or Upsert
+	Queries  Queries
+
+	// Conflict holds the columns of the unique constraint Upsert conflicts on e.g "email".
+	// Defaults to the primary key.
+	Conflict []string
+
+	// Keyset holds the columns ordering the pages of GetPage, prefixed with "-" for a
+	// descending order e.g "-created_at". Primary key columns are appended if missing.
+	Keyset []string
+
+	// DeletedAt is the column of the gorm.DeletedAt field of models soft deleted by GORM
+	// e.g "deleted_at". Empty for models that are permanently deleted.
+	DeletedAt string
+
+	// Version is the column of the integer field of models updated with optimistic locking
+	// e.g "version". Updates only apply to the version read and increment it.
+	Version string
+
+	// Audit records the writes of Create, CreateMany, Update, PartialUpdate,
+	// PartialUpdateWithMap, Delete, SoftDelete and ForceDelete in the audit table,
+	// in their transaction. See AuditEntry.
+	Audit bool
+
+	// Tenant is the column of the models scoped to the tenant of the context of their queries
+	// by UseTenancy e.g "tenant_id". Update and the upserts never write across tenants.
+	Tenant string
+
+	// Key functions, nil for models without a primary key.
+	PrimaryKey    func(record *T) PK
+	SetPrimaryKey func(record *T, id PK)
+	KeyCondition  func(keys ...PK) clause.Expression // Matches the records with any of the keys
+}
+
+// PaginatedResults defines options for paginated queries.
+type PaginatedResults[T any] struct {
+	Page       int   `json:"page"`
+	PageSize   int   `json:"page_size"`
+	TotalPages int64 `json:"total_pages"`
+	Count      int64 `json:"count"`
+	HasNext    bool  `json:"has_next"`
+	HasPrev    bool  `json:"has_prev"`
+	Results    []T   `json:"results"`
+}
+
+// Repo implements the queries of model T with primary key PK.
+// A Repo is safe for concurrent use, PreloadAll, Preload, WithTrashed, WithCount, WithContext,
+// WithHooks and Begin return copies and never modify the original.
+type Repo[T any, PK comparable] struct {
+	DB *gorm.DB
+
+	meta              *Meta[T, PK]
+	preloadAll        bool
+	preloadConfigured bool
+	withTrashed       bool
+	withCount         bool
+	hooks             *HookSet[*T]  // Hooks of T
+	global            *HookSet[any] // Hooks of all models, nil without WithHooks
+}
+
+// New returns a repository of T running its queries with db.
+func New[T any, PK comparable](db *gorm.DB, meta *Meta[T, PK]) *Repo[T, PK] {
+	if meta.Table != "" {
+		// A new session keeps the table for every query.
+		db = db.Table(meta.Table).Session(&gorm.Session{})
+	}
+	return &Repo[T, PK]{DB: db, meta: meta, hooks: new(HookSet[*T])}
+}
+
+// PreloadAll returns a copy of the repository preloading all the configured relations or none.
+func (r Repo[T, PK]) PreloadAll(preload bool) *Repo[T, PK] {
+	r.preloadAll = preload
+	r.preloadConfigured = true
+	return &r
+}
+
+// Preload returns a copy of the repository preloading query instead of the configured relations.
+func (r Repo[T, PK]) Preload(query string, args ...any) *Repo[T, PK] {
+	r.preloadAll = false
+	r.preloadConfigured = true
+	// A new session keeps the preload out of the statements built by each query,
+	// otherwise their conditions would pile up on the shared statement.
+	r.DB = r.DB.Preload(query, args...).Session(&gorm.Session{})
+	return &r
+}
+
+// WithTrashed returns a copy of the repository whose reads include soft deleted records.
+func (r Repo[T, PK]) WithTrashed() *Repo[T, PK] {
+	r.withTrashed = true
+	return &r
+}
+
+// WithContext returns a copy of the repository running its queries with ctx.
+func (r Repo[T, PK]) WithContext(ctx context.Context) *Repo[T, PK] {
+	r.DB = r.DB.WithContext(ctx)
+	return &r
+}
+
+// shouldPreload returns whether to preload the configured relations in a method
+// preloading them by default when defaultValue is true.
+func (r *Repo[T, PK]) shouldPreload(defaultValue bool) bool {
+	if r.preloadConfigured {
+		return r.preloadAll
+	}
+	return defaultValue
+}
+
+// shouldRefetch returns whether a method with settings refetches the records it writes.
+func (r *Repo[T, PK]) shouldRefetch(settings QuerySettings) bool {
+	return settings.RefetchAfterWrite && r.meta.KeyCondition != nil &&
+		r.shouldPreload(settings.PreloadAll) && len(r.meta.Preloads) > 0
+}
+
+// query returns a query reading T, preloading the configured relations if preload is true.
+func (r *Repo[T, PK]) query(preload bool, scopes []Scope) *gorm.DB {
+	db := r.DB
+	if r.withTrashed {
+		db = db.Unscoped()
+	}
+	if len(r.meta.Hidden) > 0 {
+		// Hidden columns are never read.
+		db = db.Omit(r.meta.Hidden...)
+	}
+	if preload {
+		for _, preloadStmt := range r.meta.Preloads {
+			db = db.Preload(preloadStmt)
+		}
+	}
+	return r.applyScopes(db, scopes)
+}
+
+// applyScopes applies scopes to db right away, unlike gorm.DB.Scopes
+// that defers them until the query runs. The scopes can look up the
+// queried model, see StrictOrder.
+func (r *Repo[T, PK]) applyScopes(db *gorm.DB, scopes []Scope) *gorm.DB {
+	if len(scopes) == 0 {
+		return db
+	}
+	db = db.Set(modelKey, scopeModel{value: new(T), hidden: r.meta.Hidden})
+	for _, scope := range scopes {
+		if scope != nil {
+			db = scope(db)
+		}
+	}
+	return db
+}
+
+func (r *Repo[T, PK]) get(id PK, preload bool, scopes []Scope) (*T, error) {
+	if r.meta.KeyCondition == nil {
+		return nil, ErrNoPrimaryKey
+	}
+
+	var record T
+	if err := r.query(preload, scopes).Where(r.meta.KeyCondition(id)).First(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Get a single record by primary key.
+// Warning: Do not pass a Where scope when using id, you will get unexpected results.
+func (r *Repo[T, PK]) Get(id PK, scopes ...Scope) (*T, error) {
+	return intercept(r, "Get", func() (*T, error) {
+		return r.get(id, r.shouldPreload(r.meta.Queries.Get.PreloadAll), scopes)
+	})
+}
+
+// GetAll retrieves all records.
+func (r *Repo[T, PK]) GetAll(scopes ...Scope) ([]*T, error) {
+	return intercept(r, "GetAll", func() ([]*T, error) {
+		var results []*T
+		db := r.query(r.shouldPreload(r.meta.Queries.GetAll.PreloadAll), scopes)
+		if err := db.Find(&results).Error; err != nil {
+			return nil, err
+		}
+		return results, nil
+	})
+}
+
+// Count returns the number of records matching the query.
+func (r *Repo[T, PK]) Count(scopes ...Scope) (int64, error) {
+	return intercept(r, "Count", func() (int64, error) {
+		var count int64
+		db := r.DB
+		if r.withTrashed {
+			db = db.Unscoped()
+		}
+		db = r.applyScopes(db, scopes)
+		if err := db.Model(new(T)).Count(&count).Error; err != nil {
+			return 0, err
+		}
+		return count, nil
+	})
+}
+
+// GetPaginated retrieves a page of pageSize records. Pages start at 1.
+func (r *Repo[T, PK]) GetPaginated(page int, pageSize int, scopes ...Scope) (*PaginatedResults[*T], error) {
+	return intercept(r, "GetPaginated", func() (*PaginatedResults[*T], error) {
+		// Page must be >= 1
+		if page < 1 {
+			page = 1
+		}
+		offset := (page - 1) * pageSize
+
+		db := r.query(r.shouldPreload(r.meta.Queries.GetPaginated.PreloadAll), scopes)
+
+		// Retrieve total count of records after applying scopes
+		var totalCount int64
+		if err := db.Model(new(T)).Count(&totalCount).Error; err != nil {
+			return nil, err
+		}
+
+		var results []*T
+		if err := db.Offset(offset).Limit(pageSize).Find(&results).Error; err != nil {
+			return nil, err
+		}
+
+		return &PaginatedResults[*T]{
+			Page:       page,
+			PageSize:   pageSize,
+			HasNext:    int64(page*pageSize) < totalCount,
+			HasPrev:    page > 1,
+			Results:    results,
+			Count:      totalCount,
+			TotalPages: int64(math.Ceil(float64(totalCount) / float64(pageSize))),
+		}, nil
+	})
+}
+
+// FindOne returns the first record matching the query.
+func (r *Repo[T, PK]) FindOne(scopes ...Scope) (*T, error) {
+	return intercept(r, "FindOne", func() (*T, error) {
+		var record T
+		db := r.query(r.shouldPreload(r.meta.Queries.FindOne.PreloadAll), scopes)
+		if err := db.First(&record).Error; err != nil {
+			return nil, err
+		}
+		return &record, nil
+	})
+}
+
+// FindMany returns all records matching the query.
+func (r *Repo[T, PK]) FindMany(scopes ...Scope) ([]*T, error) {
+	return intercept(r, "FindMany", func() ([]*T, error) {
+		var records []T
+		db := r.query(r.shouldPreload(r.meta.Queries.FindMany.PreloadAll), scopes)
+		if err := db.Find(&records).Error; err != nil {
+			return nil, err
+		}
+
+		results := make([]*T, len(records))
+		for i := range records {
+			results[i] = &records[i]
+		}
+		return results, nil
+	})
+}
+
+// Create a new record. The relations are loaded by refetching the record if configured.
+func (r *Repo[T, PK]) Create(record *T, scopes ...Scope) error {
+	return r.write(r.event(OpCreate, "Create", nil), []*T{record}, func(r *Repo[T, PK]) ([]*T, error) {
+		return []*T{record}, r.create(record, scopes)
+	})
+}
+
+func (r *Repo[T, PK]) create(record *T, scopes []Scope) error {
+	if err := r.DB.Omit(r.meta.Omit...).Create(record).Error; err != nil {
+		return err
+	}
+
+	// Refetch to load associations if any
+	if r.shouldRefetch(r.meta.Queries.Create) {
+		fetched, err := r.get(r.meta.PrimaryKey(record), true, scopes)
+		if err != nil {
+			return err
+		}
+		*record = *fetched
+	}
+	return nil
+}
+
+// CreateMany creates records in a single statement.
+// The relations are loaded by refetching the records if configured.
+func (r *Repo[T, PK]) CreateMany(records *[]T, scopes ...Scope) error {
+	return r.write(r.event(OpCreate, "CreateMany", nil), pointers(*records), func(r *Repo[T, PK]) ([]*T, error) {
+		return pointers(*records), r.createMany(records, scopes)
+	})
+}
+
+func (r *Repo[T, PK]) createMany(records *[]T, scopes []Scope) error {
+	if err := r.DB.Omit(r.meta.Omit...).Create(records).Error; err != nil {
+		return err
+	}
+
+	if !r.shouldRefetch(r.meta.Queries.CreateMany) {
+		return nil
+	}
+	return r.refetchMany(*records, scopes)
+}
+
+// refetchMany replaces records with their copy read from the database with
+// the configured relations.
+func (r *Repo[T, PK]) refetchMany(records []T, scopes []Scope) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	// Batch refetch to load associations (single query instead of N+1)
+	keys := make([]PK, len(records))
+	for i := range records {
+		keys[i] = r.meta.PrimaryKey(&records[i])
+	}
+
+	var fetched []T
+	db := r.query(true, scopes)
+	if err := db.Where(r.meta.KeyCondition(keys...)).Find(&fetched).Error; err != nil {
+		return err
+	}
+
+	// Map results back by primary key
+	fetchMap := make(map[PK]T, len(fetched))
+	for i := range fetched {
+		fetchMap[r.meta.PrimaryKey(&fetched[i])] = fetched[i]
+	}
+	for i, key := range keys {
+		if f, ok := fetchMap[key]; ok {
+			records[i] = f
+		}
+	}
+	return nil
+}
+
+// Upsert creates record or, if it conflicts with an existing record on Meta.Conflict,
+// updates the columns of the existing record instead. The primary key and hidden columns
+// of existing records are left untouched.
+// The relations are loaded by refetching the record if configured.
+func (r *Repo[T, PK]) Upsert(record *T, scopes ...Scope) error {
+	return r.write(r.event(OpCreate, "Upsert", nil), []*T{record}, func(r *Repo[T, PK]) ([]*T, error) {
+		return []*T{record}, r.upsert(record, scopes)
+	})
+}
+
+func (r *Repo[T, PK]) upsert(record *T, scopes []Scope) error {
+	onConflict, err := r.onConflict()
+	if err != nil {
+		return err
+	}
+	if err := r.DB.Omit(r.meta.Omit...).Clauses(onConflict).Create(record).Error; err != nil {
+		return err
+	}
+
+	if r.shouldRefetch(r.meta.Queries.Upsert) {
+		fetched, err := r.get(r.meta.PrimaryKey(record), true, scopes)
+		if err != nil {
+			return err
+		}
+		*record = *fetched
+	}
+	return nil
+}
+
+// UpsertMany upserts records in a single statement, see Upsert.
+// The relations are loaded by refetching the records if configured.
+func (r *Repo[T, PK]) UpsertMany(records *[]T, scopes ...Scope) error {
+	return r.write(r.event(OpCreate, "UpsertMany", nil), pointers(*records), func(r *Repo[T, PK]) ([]*T, error) {
+		return pointers(*records), r.upsertMany(records, scopes)
+	})
+}
+
+func (r *Repo[T, PK]) upsertMany(records *[]T, scopes []Scope) error {
+	onConflict, err := r.onConflict()
+	if err != nil {
+		return err
+	}
+	if err := r.DB.Omit(r.meta.Omit...).Clauses(onConflict).Create(records).Error; err != nil {
+		return err
+	}
+
+	if !r.shouldRefetch(r.meta.Queries.UpsertMany) {
+		return nil
+	}
+	return r.refetchMany(*records, scopes)
+}
+
+// onConflict returns the clause of the upsert methods. It updates the columns written
+// on create except the conflict columns, primary key, hidden columns and Meta.Tenant,
+// like clause.OnConflict.UpdateAll does.
+func (r *Repo[T, PK]) onConflict() (clause.OnConflict, error) {
+	sch, err := r.schema()
+	if err != nil {
+		return clause.OnConflict{}, err
+	}
+
+	conflict, err := r.conflictColumns(sch)
+	if err != nil {
+		return clause.OnConflict{}, err
+	}
+
+	var updates []string
+	for _, field := range sch.Fields {
+		// Columns left out of the insert for their database default are not updated either.
+		dbDefault := field.HasDefaultValue && field.DefaultValueInterface == nil && !strings.EqualFold(field.DefaultValue, "NULL")
+		if field.DBName == "" || field.PrimaryKey || !field.Creatable || !field.Updatable ||
+			field.AutoCreateTime > 0 || dbDefault || field.DBName == r.meta.Tenant ||
+			slices.Contains(conflict, field.DBName) || slices.Contains(r.meta.Hidden, field.DBName) {
+			continue
+		}
+		updates = append(updates, field.DBName)
+	}
+
+	onConflict := clause.OnConflict{DoUpdates: clause.AssignmentColumns(updates), DoNothing: len(updates) == 0}
+	if r.meta.Tenant != "" && !onConflict.DoNothing {
+		// Rows of other tenants conflicting with the record are left as is.
+		onConflict.Where = clause.Where{Exprs: []clause.Expression{clause.Eq{
+			Column: clause.Column{Table: clause.CurrentTable, Name: r.meta.Tenant},
+			Value:  clause.Column{Table: "excluded", Name: r.meta.Tenant},
+		}}}
+	}
+	for _, column := range conflict {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: column})
+	}
+	return onConflict, nil
+}
+
+// conflictColumns returns Meta.Conflict, or the primary key columns by default.
+func (r *Repo[T, PK]) conflictColumns(sch *schema.Schema) ([]string, error) {
+	conflict := r.meta.Conflict
+	if len(conflict) == 0 {
+		conflict = sch.PrimaryFieldDBNames
+	}
+	if len(conflict) == 0 {
+		return nil, ErrNoConflictColumns
+	}
+	return conflict, nil
+}
+
+// FirstOrCreate reads the first record matching the non-zero fields of record into it
+// or creates record if there is none. Attrs and Assign scopes are applied like
+// gorm.DB.FirstOrCreate does. Hidden columns of record are zeroed as they are never read.
+// The relations are loaded by refetching the record if configured. The create hooks
+// only run if there is no record, in the transaction of the query.
+func (r *Repo[T, PK]) FirstOrCreate(record *T, scopes ...Scope) error {
+	event := r.event(OpCreate, "FirstOrCreate", nil)
+	if !r.observed(event) {
+		return r.firstOrCreate(record, scopes)
+	}
+
+	return Transaction(event.Context, r.DB, func(tx *gorm.DB) error {
+		txRepo := *r
+		txRepo.DB = tx
+
+		conds := *record
+		db := txRepo.firstOrCreateQuery(false).Omit(r.meta.Hidden...)
+		result := txRepo.applyScopes(db, scopes).FirstOrInit(record, &conds)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			// Found records may still be updated by Assign scopes.
+			*record = conds
+			return txRepo.firstOrCreate(record, scopes)
+		}
+
+		// record is initialized with the Attrs and Assign scopes.
+		return txRepo.write(event, []*T{record}, func(r *Repo[T, PK]) ([]*T, error) {
+			if err := r.DB.Omit(r.meta.Omit...).Create(record).Error; err != nil {
+				return nil, err
+			}
+			return []*T{record}, r.firstOrCreated(record, scopes)
+		})
+	})
+}
+
+func (r *Repo[T, PK]) firstOrCreate(record *T, scopes []Scope) error {
+	// The query creates the record as well, so hidden columns are not omitted
+	// and instead zeroed afterwards.
+	db := r.firstOrCreateQuery(r.shouldPreload(r.meta.Queries.FirstOrCreate.PreloadAll)).Omit(r.meta.Omit...)
+	conds := *record
+	if err := r.applyScopes(db, scopes).FirstOrCreate(record, &conds).Error; err != nil {
+		return err
+	}
+	return r.firstOrCreated(record, scopes)
+}
+
+// firstOrCreateQuery returns the query of FirstOrCreate, preloading the relations if preload.
+func (r *Repo[T, PK]) firstOrCreateQuery(preload bool) *gorm.DB {
+	db := r.DB
+	if r.withTrashed {
+		db = db.Unscoped()
+	}
+	if preload {
+		for _, preloadStmt := range r.meta.Preloads {
+			db = db.Preload(preloadStmt)
+		}
+	}
+	return db
+}
+
+// firstOrCreated zeroes the hidden columns of the record found or created by FirstOrCreate
+// and refetches it if configured.
+func (r *Repo[T, PK]) firstOrCreated(record *T, scopes []Scope) error {
+	sch, err := r.schema()
+	if err != nil {
+		return err
+	}
+
+	value := reflect.ValueOf(record).Elem()
+	for _, column := range r.meta.Hidden {
+		if field := sch.LookUpField(column); field != nil {
+			field.ReflectValueOf(r.DB.Statement.Context, value).SetZero()
+		}
+	}
+
+	if r.shouldRefetch(r.meta.Queries.FirstOrCreate) {
+		fetched, err := r.get(r.meta.PrimaryKey(record), true, scopes)
+		if err != nil {
+			return err
+		}
+		*record = *fetched
+	}
+	return nil
+}
+
+// FirstOrInit reads the first record matching the non-zero fields of record into it.
+// record is left as is if there is none, ready to be created. Attrs and Assign scopes
+// are applied like gorm.DB.FirstOrInit does.
+func (r *Repo[T, PK]) FirstOrInit(record *T, scopes ...Scope) error {
+	_, err := intercept(r, "FirstOrInit", func() (*T, error) {
+		conds := *record
+		db := r.query(r.shouldPreload(r.meta.Queries.FirstOrInit.PreloadAll), scopes)
+		return record, db.FirstOrInit(record, &conds).Error
+	})
+	return err
+}
+
+// schema returns the parsed schema of T.
+func (r *Repo[T, PK]) schema() (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: r.DB}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, err
+	}
+	return stmt.Schema, nil
+}
+
+// Update all the fields of the record with primary key id. Uses gorm.DB.Save().
+func (r *Repo[T, PK]) Update(id PK, record *T, scopes ...Scope) (*T, error) {
+	return r.update(r.event(OpUpdate, "Update", id), record, func(r *Repo[T, PK]) (*T, error) {
+		return r.updateAll(id, record, scopes)
+	})
+}
+
+func (r *Repo[T, PK]) updateAll(id PK, record *T, scopes []Scope) (*T, error) {
+	if r.meta.KeyCondition == nil {
+		return nil, ErrNoPrimaryKey
+	}
+
+	// Make sure the primary key is set on object to use Save(), otherwise you get unique constraint error.
+	r.meta.SetPrimaryKey(record, id)
+	if r.meta.Version != "" {
+		if err := r.updateVersion(id, record); err != nil {
+			return nil, err
+		}
+	} else if r.meta.Tenant != "" {
+		if err := r.updateTenant(id, record); err != nil {
+			return nil, err
+		}
+	} else if err := r.DB.Omit(slices.Concat(r.meta.Omit, r.meta.Hidden)...).Save(record).Error; err != nil {
+		return nil, err
+	}
+
+	if r.shouldRefetch(r.meta.Queries.Update) {
+		return r.get(id, true, scopes)
+	}
+	return record, nil
+}
+
+// UpdateColumn updates a single column of the records matching query.
+// Gorm hooks will be fired because it uses Updates() method. The update hooks get a nil
+// record and the column in event.Data.
+func (r *Repo[T, PK]) UpdateColumn(columnName string, value any, query string, args ...any) error {
+	event := r.event(OpUpdate, "UpdateColumn", nil)
+	event.Data = map[string]any{columnName: value}
+	event.where = append([]any{query}, args...)
+	return r.write(event, []*T{nil}, func(r *Repo[T, PK]) ([]*T, error) {
+		return []*T{nil}, r.DB.Model(new(T)).Where(query, args...).Updates(event.Data).Error
+	})
+}
+
+// PartialUpdate only updates the fields of record with non-zero values using gorm.DB.Updates().
+// Returns the updated record. With Meta.Version, the version of record is the version read.
+func (r *Repo[T, PK]) PartialUpdate(id PK, record T, scopes ...Scope) (*T, error) {
+	return r.update(r.event(OpUpdate, "PartialUpdate", id), &record, func(r *Repo[T, PK]) (*T, error) {
+		return r.partialUpdate(id, &record, r.meta.Queries.PartialUpdate, scopes)
+	})
+}
+
+// PartialUpdateWithMap only updates the columns in data using gorm.DB.Updates().
+// Returns the updated record. With Meta.Version, data must hold the version read
+// by column or field name.
+func (r *Repo[T, PK]) PartialUpdateWithMap(id PK, data map[string]any, scopes ...Scope) (*T, error) {
+	event := r.event(OpUpdate, "PartialUpdateWithMap", id)
+	event.Data = data
+	return r.update(event, nil, func(r *Repo[T, PK]) (*T, error) {
+		return r.partialUpdate(id, event.Data, r.meta.Queries.PartialUpdateWithMap, scopes)
+	})
+}
+
+// update runs the update fn of event between its hooks, called with record before it
+// and the updated record after it.
+func (r *Repo[T, PK]) update(event *Event, record *T, fn func(r *Repo[T, PK]) (*T, error)) (*T, error) {
+	var updated *T
+	err := r.write(event, []*T{record}, func(r *Repo[T, PK]) (_ []*T, err error) {
+		updated, err = fn(r)
+		return []*T{updated}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (r *Repo[T, PK]) partialUpdate(id PK, values any, settings QuerySettings, scopes []Scope) (*T, error) {
+	if r.meta.KeyCondition == nil {
+		return nil, ErrNoPrimaryKey
+	}
+
+	db := r.DB.Omit(r.meta.Omit...).Where(r.meta.KeyCondition(id)).Model(new(T))
+	if r.meta.Version != "" {
+		if err := r.partialUpdateVersion(db, id, values); err != nil {
+			return nil, err
+		}
+	} else if err := db.Updates(values).Error; err != nil {
+		return nil, err
+	}
+
+	// Fetch the updated record, without preloads unless refetching
+	return r.get(id, r.shouldRefetch(settings), scopes)
+}
+
+// partialUpdateVersion updates the record with primary key id with values, a *T or a map,
+// if the version they hold is the current one, incrementing the version.
+func (r *Repo[T, PK]) partialUpdateVersion(db *gorm.DB, id PK, values any) error {
+	version, err := r.versionField()
+	if err != nil {
+		return err
+	}
+
+	var read int64
+	switch v := values.(type) {
+	case *T:
+		value := version.ReflectValueOf(db.Statement.Context, reflect.ValueOf(v).Elem())
+		read = versionOf(value)
+		setVersion(value, read+1)
+	case map[string]any:
+		number, ok := v[version.DBName]
+		if !ok {
+			number = v[version.Name]
+		}
+		if read, ok = versionNumber(number); !ok {
+			return fmt.Errorf("repo: data has no %s to update with, got %v", version.DBName, number)
+		}
+
+		// The map of the caller is left as is.
+		data := maps.Clone(v)
+		delete(data, version.Name)
+		data[version.DBName] = read + 1
+		values = data
+	}
+
+	result := db.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: version.DBName}, Value: read}).Updates(values)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return r.staleError(id)
+	}
+	return result.Error
+}
+
+// updateVersion updates all the fields of record if its version is the current one,
+// incrementing the version. The version of record is left as is on failure.
+func (r *Repo[T, PK]) updateVersion(id PK, record *T) error {
+	version, err := r.versionField()
+	if err != nil {
+		return err
+	}
+
+	// Unlike Save, Updates does not create the record when no row matches.
+	db := r.DB.Model(record).Select("*").Omit(slices.Concat(r.meta.Omit, r.meta.Hidden)...)
+	value := version.ReflectValueOf(db.Statement.Context, reflect.ValueOf(record).Elem())
+	read := versionOf(value)
+	setVersion(value, read+1)
+
+	result := db.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: version.DBName}, Value: read}).Updates(record)
+	if result.Error == nil && result.RowsAffected == 0 {
+		result.Error = r.staleError(id)
+	}
+	if result.Error != nil {
+		setVersion(value, read)
+	}
+	return result.Error
+}
+
+// updateTenant updates all the fields of record like Save does, without Save creating the
+// record when no row of the tenant matches, which would overwrite the row of another tenant.
+func (r *Repo[T, PK]) updateTenant(id PK, record *T) error {
+	result := r.DB.Model(record).Select("*").Omit(slices.Concat(r.meta.Omit, r.meta.Hidden)...).Updates(record)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+
+	// Rows updated with their current values may not be counted as affected.
+	var count int64
+	if err := r.DB.Model(new(T)).Where(r.meta.KeyCondition(id)).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// versionField returns the field of Meta.Version.
+func (r *Repo[T, PK]) versionField() (*schema.Field, error) {
+	sch, err := r.schema()
+	if err != nil {
+		return nil, err
+	}
+	field := sch.LookUpField(r.meta.Version)
+	if field == nil {
+		return nil, fmt.Errorf("repo: unknown version column %q", r.meta.Version)
+	}
+	return field, nil
+}
+
+// staleError returns the error of an update of the record with primary key id matching
+// no row: ErrStaleObject if the record exists, gorm.ErrRecordNotFound otherwise.
+func (r *Repo[T, PK]) staleError(id PK) error {
+	var count int64
+	if err := r.DB.Model(new(T)).Where(r.meta.KeyCondition(id)).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return ErrStaleObject
+}
+
+// versionOf returns the version held by the integer value of a version field.
+func versionOf(value reflect.Value) int64 {
+	if value.CanInt() {
+		return value.Int()
+	}
+	return int64(value.Uint())
+}
+
+// setVersion sets the integer value of a version field.
+func setVersion(value reflect.Value, version int64) {
+	if value.CanInt() {
+		value.SetInt(version)
+	} else {
+		value.SetUint(uint64(version))
+	}
+}
+
+// versionNumber converts the version of an update map to an integer, accepting
+// the float64 numbers of decoded JSON.
+func versionNumber(v any) (int64, bool) {
+	value := reflect.ValueOf(v)
+	switch {
+	case value.CanInt():
+		return value.Int(), true
+	case value.CanUint():
+		return int64(value.Uint()), true
+	case value.CanFloat() && value.Float() == math.Trunc(value.Float()):
+		return int64(value.Float()), true
+	}
+	return 0, false
+}
+
+// Delete deletes the record with primary key id. Models with Meta.DeletedAt are soft
+// deleted, see ForceDelete to delete them permanently.
+func (r *Repo[T, PK]) Delete(id PK) error {
+	return r.deleteByKey("Delete", id, false)
+}
+
+// deleteByKey deletes the record with primary key id, permanently if unscoped.
+func (r *Repo[T, PK]) deleteByKey(method string, id PK, unscoped bool) error {
+	if r.meta.KeyCondition == nil {
+		return ErrNoPrimaryKey
+	}
+	return r.delete(r.event(OpDelete, method, id), func(r *Repo[T, PK]) error {
+		db := r.DB
+		if unscoped {
+			db = db.Unscoped()
+		}
+		return db.Where(r.meta.KeyCondition(id)).Delete(new(T)).Error
+	})
+}
+
+// SoftDelete sets the deleted_at column of the record with primary key id.
+// Soft deleted records are left out of reads unless using WithTrashed.
+func (r *Repo[T, PK]) SoftDelete(id PK) error {
+	if err := r.checkSoftDelete(); err != nil {
+		return err
+	}
+	return r.deleteByKey("SoftDelete", id, false)
+}
+
+// Restore clears the deleted_at column of the soft deleted record with primary key id.
+// It returns gorm.ErrRecordNotFound if no soft deleted record has this key.
+func (r *Repo[T, PK]) Restore(id PK) error {
+	if err := r.checkSoftDelete(); err != nil {
+		return err
+	}
+
+	event := r.event(OpUpdate, "Restore", id)
+	return r.write(event, []*T{nil}, func(r *Repo[T, PK]) ([]*T, error) {
+		deleted := clause.Neq{Column: clause.Column{Table: clause.CurrentTable, Name: r.meta.DeletedAt}, Value: nil}
+		result := r.DB.Unscoped().Model(new(T)).Where(r.meta.KeyCondition(id)).Where(deleted).Update(r.meta.DeletedAt, nil)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, gorm.ErrRecordNotFound
+		}
+		if !r.observed(event) {
+			return []*T{nil}, nil
+		}
+
+		// The after hooks and audit entry get the restored record.
+		record, err := r.snapshot(r.meta.KeyCondition(id), false)
+		return []*T{record}, err
+	})
+}
+
+// ForceDelete permanently deletes the record with primary key id, soft deleted or not.
+func (r *Repo[T, PK]) ForceDelete(id PK) error {
+	return r.deleteByKey("ForceDelete", id, true)
+}
+
+// GetDeleted gets a soft deleted record by primary key.
+func (r *Repo[T, PK]) GetDeleted(id PK, scopes ...Scope) (*T, error) {
+	return intercept(r, "GetDeleted", func() (*T, error) {
+		if err := r.checkSoftDelete(); err != nil {
+			return nil, err
+		}
+
+		var record T
+		db := r.deleted(r.shouldPreload(r.meta.Queries.Get.PreloadAll), scopes)
+		if err := db.Where(r.meta.KeyCondition(id)).First(&record).Error; err != nil {
+			return nil, err
+		}
+		return &record, nil
+	})
+}
+
+// FindDeleted returns the soft deleted records matching the query.
+func (r *Repo[T, PK]) FindDeleted(scopes ...Scope) ([]*T, error) {
+	return intercept(r, "FindDeleted", func() ([]*T, error) {
+		if r.meta.DeletedAt == "" {
+			return nil, ErrNoSoftDelete
+		}
+
+		var results []*T
+		db := r.deleted(r.shouldPreload(r.meta.Queries.FindMany.PreloadAll), scopes)
+		if err := db.Find(&results).Error; err != nil {
+			return nil, err
+		}
+		return results, nil
+	})
+}
+
+// deleted returns a query reading soft deleted records only.
+func (r *Repo[T, PK]) deleted(preload bool, scopes []Scope) *gorm.DB {
+	deleted := clause.Neq{Column: clause.Column{Table: clause.CurrentTable, Name: r.meta.DeletedAt}, Value: nil}
+	return r.query(preload, scopes).Unscoped().Where(deleted)
+}
+
+// checkSoftDelete returns an error if records of T can't be soft deleted by primary key.
+func (r *Repo[T, PK]) checkSoftDelete() error {
+	if r.meta.KeyCondition == nil {
+		return ErrNoPrimaryKey
+	}
+	if r.meta.DeletedAt == "" {
+		return ErrNoSoftDelete
+	}
+	return nil
+}
+
+// DeleteWhere deletes the records matching conditions, soft deleting them for models
+// with Meta.DeletedAt.
+func (r *Repo[T, PK]) DeleteWhere(value string, conds ...any) error {
+	event := r.event(OpDelete, "DeleteWhere", nil)
+	event.where = append([]any{value}, conds...)
+	return r.delete(event, func(r *Repo[T, PK]) error {
+		return r.DB.Delete(new(T), append([]any{value}, conds...)...).Error
+	})
+}
+
+// delete runs the delete fn of event between its hooks.
+func (r *Repo[T, PK]) delete(event *Event, fn func(r *Repo[T, PK]) error) error {
+	return r.write(event, []*T{nil}, func(r *Repo[T, PK]) ([]*T, error) {
+		return []*T{nil}, fn(r)
+	})
+}
+
+// pointers returns pointers to the records, for the hooks of the methods writing several.
+func pointers[T any](records []T) []*T {
+	results := make([]*T, len(records))
+	for i := range records {
+		results[i] = &records[i]
+	}
+	return results
+}
+
+// Begin returns a copy of the repository that runs all queries in a transaction.
+// Call Rollback() to undo changes and Commit() to Commit the changes.
+func (r Repo[T, PK]) Begin(opts ...*sql.TxOptions) (*Repo[T, PK], error) {
+	tx := r.DB.Begin(opts...)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	r.DB = tx
+	return &r, nil
+}
+
+// Commit the transaction started with Begin.
+func (r *Repo[T, PK]) Commit() error {
+	return r.DB.Commit().Error
+}
+
+// Rollback the transaction started with Begin.
+func (r *Repo[T, PK]) Rollback() error {
+	return r.DB.Rollback().Error
+}
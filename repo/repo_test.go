@@ -0,0 +1,445 @@
+package repo
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/utils/tests"
+)
+
+type Role struct {
+	ID   uint
+	Name string
+}
+
+type User struct {
+	ID       uint
+	Name     string
+	Password string
+	RoleID   uint
+	Role     *Role
+}
+
+var userMeta = &Meta[User, uint]{
+	Table:    "app_users",
+	Preloads: []string{"Role"},
+	Omit:     []string{"Role"},
+	Hidden:   []string{"password"},
+	Queries: Queries{
+		Get:    QuerySettings{PreloadAll: true},
+		GetAll: QuerySettings{PreloadAll: false},
+		Create: QuerySettings{PreloadAll: true, RefetchAfterWrite: true},
+	},
+	PrimaryKey:    func(u *User) uint { return u.ID },
+	SetPrimaryKey: func(u *User, id uint) { u.ID = id },
+	KeyCondition: func(keys ...uint) clause.Expression {
+		values := make([]any, len(keys))
+		for i, id := range keys {
+			values[i] = id
+		}
+		return clause.IN{Column: clause.Column{Table: clause.CurrentTable, Name: "id"}, Values: values}
+	},
+}
+
+// statement is a statement run in dry run mode.
+type statement struct {
+	SQL      string
+	Preloads []string
+}
+
+// dryRun returns a database building statements without running them and the statements built.
+func dryRun(t *testing.T) (*gorm.DB, func() []statement) {
+	t.Helper()
+	db, err := gorm.Open(tests.DummyDialector{}, &gorm.Config{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var statements []statement
+	capture := func(tx *gorm.DB) {
+		mu.Lock()
+		defer mu.Unlock()
+		var preloads []string
+		for preload := range tx.Statement.Preloads {
+			preloads = append(preloads, preload)
+		}
+		slices.Sort(preloads)
+		statements = append(statements, statement{SQL: tx.Statement.SQL.String(), Preloads: preloads})
+	}
+
+	callbacks := db.Callback()
+	for name, err := range map[string]error{
+		"query":  callbacks.Query().After("gorm:query").Register("test:capture", capture),
+		"create": callbacks.Create().After("gorm:create").Register("test:capture", capture),
+		"update": callbacks.Update().After("gorm:update").Register("test:capture", capture),
+		"delete": callbacks.Delete().After("gorm:delete").Register("test:capture", capture),
+	} {
+		if err != nil {
+			t.Fatalf("registering %s callback: %v", name, err)
+		}
+	}
+
+	return db, func() []statement {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(statements)
+	}
+}
+
+func TestGet(t *testing.T) {
+	db, statements := dryRun(t)
+	users := New(db, userMeta)
+
+	if _, err := users.Get(1, func(db *gorm.DB) *gorm.DB { return db.Where("name = ?", "john") }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.GetAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := statements()
+	if len(got) != 2 {
+		t.Fatalf("expected 2 statements, got %v", got)
+	}
+
+	want := "SELECT `app_users`.`id`,`app_users`.`name`,`app_users`.`role_id` FROM `app_users` WHERE name = ? AND `app_users`.`id` = ? ORDER BY `app_users`.`id` LIMIT ?"
+	if got[0].SQL != want {
+		t.Errorf("expected Get to run\n%s\ngot\n%s", want, got[0].SQL)
+	}
+	if !slices.Equal(got[0].Preloads, []string{"Role"}) {
+		t.Errorf("expected Get to preload Role, got %v", got[0].Preloads)
+	}
+	if len(got[1].Preloads) != 0 {
+		t.Errorf("expected GetAll not to preload by default, got %v", got[1].Preloads)
+	}
+}
+
+func TestPreloadReturnsCopies(t *testing.T) {
+	db, statements := dryRun(t)
+	users := New(db, userMeta)
+	shared := *users
+
+	if _, err := users.PreloadAll(false).Get(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.PreloadAll(true).GetAll(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.Preload("Role", "name = ?", "admin").FindMany(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.Get(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if *users != shared {
+		t.Errorf("expected the repository not to be modified by chaining")
+	}
+
+	got := statements()
+	for i, want := range [][]string{nil, {"Role"}, {"Role"}, {"Role"}} {
+		if !slices.Equal(got[i].Preloads, want) {
+			t.Errorf("statement %d: expected preloads %v, got %v", i, want, got[i].Preloads)
+		}
+	}
+}
+
+func TestWrites(t *testing.T) {
+	db, statements := dryRun(t)
+	users := New(db, userMeta)
+
+	user := User{Name: "john", Password: "secret", Role: &Role{Name: "admin"}}
+	if err := users.Create(&user); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.Update(7, &user); err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != 7 {
+		t.Errorf("expected Update to set the primary key, got %d", user.ID)
+	}
+	if err := users.Delete(7); err != nil {
+		t.Fatal(err)
+	}
+
+	var sql []string
+	for _, stmt := range statements() {
+		sql = append(sql, stmt.SQL)
+	}
+
+	for _, want := range []string{
+		"INSERT INTO `app_users` (`name`,`password`,`role_id`) VALUES (?,?,?)",
+		"SELECT `app_users`.`id`,`app_users`.`name`,`app_users`.`role_id` FROM `app_users` WHERE `app_users`.`id` = ?", // Create refetch
+		"UPDATE `app_users` SET `name`=?,`role_id`=? WHERE `id` = ?",
+		"DELETE FROM `app_users` WHERE `app_users`.`id` = ?",
+	} {
+		if !slices.ContainsFunc(sql, func(s string) bool { return strings.HasPrefix(s, want) }) {
+			t.Errorf("expected a statement starting with\n%s\ngot\n%s", want, strings.Join(sql, "\n"))
+		}
+	}
+	if slices.ContainsFunc(sql, func(s string) bool { return strings.Contains(s, "INSERT INTO `roles`") }) {
+		t.Errorf("expected associations to be omitted on writes")
+	}
+}
+
+func TestNoPrimaryKey(t *testing.T) {
+	db, _ := dryRun(t)
+	logs := New(db, &Meta[User, NoKey]{})
+
+	if _, err := logs.Get(NoKey{}); !errors.Is(err, ErrNoPrimaryKey) {
+		t.Errorf("expected ErrNoPrimaryKey, got %v", err)
+	}
+	if err := logs.Delete(NoKey{}); !errors.Is(err, ErrNoPrimaryKey) {
+		t.Errorf("expected ErrNoPrimaryKey, got %v", err)
+	}
+	if _, err := logs.FindMany(); err != nil {
+		t.Errorf("expected queries without a key to run, got %v", err)
+	}
+}
+
+type Invoice struct {
+	ID        uint
+	Total     int
+	DeletedAt gorm.DeletedAt
+}
+
+func TestSoftDelete(t *testing.T) {
+	db, statements := dryRun(t)
+	invoices := New(db, &Meta[Invoice, uint]{
+		DeletedAt:    "deleted_at",
+		PrimaryKey:   func(i *Invoice) uint { return i.ID },
+		KeyCondition: func(keys ...uint) clause.Expression { return clause.Eq{Column: clause.PrimaryColumn, Value: keys[0]} },
+	})
+
+	// Updates match a row until affected is reset.
+	affected := int64(1)
+	err := db.Callback().Update().After("gorm:update").Register("test:rows", func(tx *gorm.DB) {
+		tx.RowsAffected = affected
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, run := range []func() error{
+		func() error { return invoices.SoftDelete(1) },
+		func() error { return invoices.Restore(1) },
+		func() error { return invoices.Delete(1) },
+		func() error { return invoices.DeleteWhere("total > ?", 100) },
+		func() error { return invoices.ForceDelete(1) },
+		func() error { _, err := invoices.Get(1); return err },
+		func() error { _, err := invoices.WithTrashed().FindMany(); return err },
+		func() error { _, err := invoices.GetDeleted(1); return err },
+		func() error { _, err := invoices.FindDeleted(); return err },
+	} {
+		if err := run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	for _, stmt := range statements() {
+		got = append(got, stmt.SQL)
+	}
+	want := []string{
+		"UPDATE `invoices` SET `deleted_at`=? WHERE `invoices`.`id` = ? AND `invoices`.`deleted_at` IS NULL",
+		"UPDATE `invoices` SET `deleted_at`=? WHERE `invoices`.`id` = ? AND `invoices`.`deleted_at` IS NOT NULL",
+		"UPDATE `invoices` SET `deleted_at`=? WHERE `invoices`.`id` = ? AND `invoices`.`deleted_at` IS NULL",
+		"UPDATE `invoices` SET `deleted_at`=? WHERE total > ? AND `invoices`.`deleted_at` IS NULL",
+		"DELETE FROM `invoices` WHERE `invoices`.`id` = ?",
+		"SELECT * FROM `invoices` WHERE `invoices`.`id` = ? AND `invoices`.`deleted_at` IS NULL ORDER BY `invoices`.`id` LIMIT ?",
+		"SELECT * FROM `invoices`",
+		"SELECT * FROM `invoices` WHERE `invoices`.`deleted_at` IS NOT NULL AND `invoices`.`id` = ? ORDER BY `invoices`.`id` LIMIT ?",
+		"SELECT * FROM `invoices` WHERE `invoices`.`deleted_at` IS NOT NULL",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected statements\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	affected = 0
+	if err := invoices.Restore(2); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected gorm.ErrRecordNotFound restoring no soft deleted record, got %v", err)
+	}
+
+	users := New(db, userMeta)
+	if err := users.SoftDelete(1); !errors.Is(err, ErrNoSoftDelete) {
+		t.Errorf("expected ErrNoSoftDelete for models without gorm.DeletedAt, got %v", err)
+	}
+}
+
+func TestDeleteWhere(t *testing.T) {
+	db, statements := dryRun(t)
+
+	var vars []any
+	err := db.Callback().Delete().After("gorm:delete").Register("test:vars", func(tx *gorm.DB) {
+		vars = tx.Statement.Vars
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := New(db, userMeta).DeleteWhere("name = ? AND role_id = ?", "jo", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "DELETE FROM `app_users` WHERE name = ? AND role_id = ?"
+	if got := statements(); len(got) != 1 || got[0].SQL != want {
+		t.Errorf("expected %s, got %v", want, got)
+	}
+	if !reflect.DeepEqual(vars, []any{"jo", 2}) {
+		t.Errorf("expected the vars jo and 2, got %v", vars)
+	}
+}
+
+type Account struct {
+	ID        uint
+	Email     string
+	Name      string
+	Password  string
+	CreatedAt time.Time
+}
+
+func TestUpsert(t *testing.T) {
+	db, statements := dryRun(t)
+	accounts := New(db, &Meta[Account, uint]{
+		Hidden:       []string{"password"},
+		Conflict:     []string{"email"},
+		PrimaryKey:   func(a *Account) uint { return a.ID },
+		KeyCondition: func(keys ...uint) clause.Expression { return clause.Eq{Column: clause.PrimaryColumn, Value: keys[0]} },
+	})
+
+	account := Account{Email: "john@example.com", Name: "john"}
+	created := Account{Email: "john@example.com"}
+	attrs := func(db *gorm.DB) *gorm.DB { return db.Attrs(Account{Password: "secret"}) }
+	for _, run := range []func() error{
+		func() error { return accounts.Upsert(&account) },
+		func() error { return accounts.UpsertMany(&[]Account{account, {Email: "jane@example.com"}}) },
+		func() error { return accounts.FirstOrInit(&Account{Email: "john@example.com"}) },
+		func() error { return accounts.FirstOrCreate(&created, attrs) },
+	} {
+		if err := run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	for _, stmt := range statements() {
+		got = append(got, stmt.SQL)
+	}
+	want := []string{
+		"INSERT INTO `accounts` (`email`,`name`,`password`,`created_at`) VALUES (?,?,?,?) ON CONFLICT (`email`) DO UPDATE SET `name`=`excluded`.`name` RETURNING `id`",
+		"INSERT INTO `accounts` (`email`,`name`,`password`,`created_at`) VALUES (?,?,?,?),(?,?,?,?) ON CONFLICT (`email`) DO UPDATE SET `name`=`excluded`.`name` RETURNING `id`",
+		"SELECT `accounts`.`id`,`accounts`.`email`,`accounts`.`name`,`accounts`.`created_at` FROM `accounts` WHERE `accounts`.`email` = ? ORDER BY `accounts`.`id` LIMIT ?",
+		"SELECT * FROM `accounts` WHERE `accounts`.`email` = ? ORDER BY `accounts`.`id` LIMIT ?", // FirstOrCreate writes hidden columns
+		"INSERT INTO `accounts` (`email`,`name`,`password`,`created_at`) VALUES (?,?,?,?) RETURNING `id`",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected statements\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+	if created.Password != "" {
+		t.Errorf("expected FirstOrCreate to zero hidden columns, got %q", created.Password)
+	}
+
+	noKey := New(db, &Meta[Account, NoKey]{})
+	if err := noKey.Upsert(&account); err != nil {
+		t.Errorf("expected Upsert to default to the primary key of the schema, got %v", err)
+	}
+	type Log struct{ Message string }
+	logs := New(db, &Meta[Log, NoKey]{})
+	if err := logs.Upsert(&Log{}); !errors.Is(err, ErrNoConflictColumns) {
+		t.Errorf("expected ErrNoConflictColumns, got %v", err)
+	}
+}
+
+type Document struct {
+	ID      uint
+	Title   string
+	Version int
+}
+
+func TestOptimisticLocking(t *testing.T) {
+	db, statements := dryRun(t)
+
+	// Updates affect rows unless stale, and count the documents that exist.
+	var stale bool
+	var exists int64 = 1
+	err := db.Callback().Update().After("gorm:update").Register("test:rows", func(tx *gorm.DB) {
+		if !stale {
+			tx.RowsAffected = 1
+		}
+	})
+	if err == nil {
+		err = db.Callback().Query().After("gorm:query").Register("test:count", func(tx *gorm.DB) {
+			if count, ok := tx.Statement.Dest.(*int64); ok {
+				*count, tx.RowsAffected = exists, 1
+			}
+		})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	documents := New(db, &Meta[Document, uint]{
+		Version:       "version",
+		PrimaryKey:    func(d *Document) uint { return d.ID },
+		SetPrimaryKey: func(d *Document, id uint) { d.ID = id },
+		KeyCondition:  func(keys ...uint) clause.Expression { return clause.Eq{Column: clause.PrimaryColumn, Value: keys[0]} },
+	})
+
+	document := &Document{Title: "Draft", Version: 3}
+	if _, err := documents.Update(1, document); err != nil {
+		t.Fatal(err)
+	}
+	if document.Version != 4 {
+		t.Errorf("expected the version to be incremented to 4, got %d", document.Version)
+	}
+	if _, err := documents.PartialUpdate(1, Document{Title: "Final", Version: 4}); err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]any{"title": "Final", "Version": float64(5)} // decoded JSON
+	if _, err := documents.PartialUpdateWithMap(1, data); err != nil {
+		t.Fatal(err)
+	}
+	if data["Version"] != float64(5) {
+		t.Errorf("expected the map to be left as is, got %v", data)
+	}
+
+	stale = true
+	if _, err := documents.Update(1, document); !errors.Is(err, ErrStaleObject) {
+		t.Errorf("expected ErrStaleObject, got %v", err)
+	}
+	if document.Version != 4 {
+		t.Errorf("expected the version to be restored to 4, got %d", document.Version)
+	}
+	exists = 0
+	if _, err := documents.PartialUpdate(1, Document{Title: "Gone"}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected gorm.ErrRecordNotFound for a missing record, got %v", err)
+	}
+	if _, err := documents.PartialUpdateWithMap(1, map[string]any{"title": "Final"}); err == nil {
+		t.Errorf("expected an error for data without the version")
+	}
+
+	var got []string
+	for _, stmt := range statements() {
+		if strings.HasPrefix(stmt.SQL, "UPDATE") {
+			got = append(got, stmt.SQL)
+		}
+	}
+	want := []string{
+		"UPDATE `documents` SET `title`=?,`version`=? WHERE `documents`.`version` = ? AND `id` = ?",
+		"UPDATE `documents` SET `title`=?,`version`=? WHERE `documents`.`id` = ? AND `documents`.`version` = ?",
+		"UPDATE `documents` SET `title`=?,`version`=? WHERE `documents`.`id` = ? AND `documents`.`version` = ?",
+		"UPDATE `documents` SET `title`=?,`version`=? WHERE `documents`.`version` = ? AND `id` = ?",
+		"UPDATE `documents` SET `title`=?,`version`=? WHERE `documents`.`id` = ? AND `documents`.`version` = ?",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected statements\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
@@ -0,0 +1,195 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrUnknownModel is the error of strict scopes applied to queries of no known model.
+var ErrUnknownModel = errors.New("repo: strict identifiers need the model of the query")
+
+// modelKey is the gorm.DB setting holding the scopeModel of a query.
+const modelKey = "repo:model"
+
+// scopeModel is the model queried by a Repo, for the scopes validating identifiers.
+type scopeModel struct {
+	value  any      // Pointer to a zero model
+	hidden []string // Meta.Hidden columns, never named by strict scopes
+}
+
+// IdentifierError is the error of queries whose strict scopes name a column or order
+// direction that the queried model does not have. The query is not run.
+type IdentifierError struct {
+	Clause     string // SQL clause of the identifier e.g "ORDER BY"
+	Identifier string // Identifier as passed e.g "name DESC"
+	Err        error  // ErrUnknownField for unknown columns
+}
+
+func (e *IdentifierError) Error() string {
+	return fmt.Sprintf("repo: invalid %s identifier %q: %v", e.Clause, e.Identifier, e.Err)
+}
+
+func (e *IdentifierError) Unwrap() error {
+	return e.Err
+}
+
+// StrictOrder orders db by order like gorm.DB.Order, accepting only comma separated
+// columns of the queried model optionally followed by ASC or DESC e.g "Role.name DESC, id".
+// Columns are named by column or field name, and relation paths e.g "Role.Name" name the
+// columns of relations joined with gorm.DB.Joins. Otherwise the query fails with an
+// *IdentifierError. The model is known for the queries of a Repo, or set with gorm.DB.Model.
+func StrictOrder(db *gorm.DB, order string) *gorm.DB {
+	if strings.TrimSpace(order) == "" {
+		return db
+	}
+
+	var columns []clause.OrderByColumn
+	for item := range strings.SplitSeq(order, ",") {
+		item = strings.TrimSpace(item)
+		name, direction, _ := strings.Cut(item, " ")
+		column, err := resolveColumn(db, name)
+		if err != nil {
+			return addError(db, &IdentifierError{Clause: "ORDER BY", Identifier: item, Err: err})
+		}
+
+		var desc bool
+		switch strings.ToUpper(strings.TrimSpace(direction)) {
+		case "", "ASC":
+		case "DESC":
+			desc = true
+		default:
+			err := fmt.Errorf("invalid direction %q", strings.TrimSpace(direction))
+			return addError(db, &IdentifierError{Clause: "ORDER BY", Identifier: item, Err: err})
+		}
+		columns = append(columns, clause.OrderByColumn{Column: column, Desc: desc})
+	}
+	return db.Order(clause.OrderBy{Columns: columns})
+}
+
+// StrictSelect selects columns like gorm.DB.Select, accepting only the columns of the
+// queried model and "*". Arguments may hold several comma separated columns. See StrictOrder.
+func StrictSelect(db *gorm.DB, columns ...string) *gorm.DB {
+	var names []string
+	for _, arg := range columns {
+		for name := range strings.SplitSeq(arg, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				names = append(names, name)
+				continue
+			}
+			column, err := resolveColumn(db, name)
+			if err != nil {
+				return addError(db, &IdentifierError{Clause: "SELECT", Identifier: name, Err: err})
+			}
+			// Columns of the model are selected by name so that writes can match them
+			// to fields, columns of relations are quoted as GORM passes them verbatim.
+			if column.Table != clause.CurrentTable {
+				column.Name = db.Statement.Quote(column)
+			}
+			names = append(names, column.Name)
+		}
+	}
+	return db.Select(names)
+}
+
+// StrictGroup groups db by the comma separated columns of group like gorm.DB.Group,
+// accepting only the columns of the queried model. See StrictOrder.
+func StrictGroup(db *gorm.DB, group string) *gorm.DB {
+	if strings.TrimSpace(group) == "" {
+		return db
+	}
+
+	var columns []clause.Column
+	for name := range strings.SplitSeq(group, ",") {
+		name = strings.TrimSpace(name)
+		column, err := resolveColumn(db, name)
+		if err != nil {
+			return addError(db, &IdentifierError{Clause: "GROUP BY", Identifier: name, Err: err})
+		}
+		columns = append(columns, column)
+	}
+	return db.Clauses(clause.GroupBy{Columns: columns})
+}
+
+// StrictILike matches the records whose column contains value case-insensitively,
+// accepting only a column of the queried model. Does nothing if value is empty.
+// See StrictOrder.
+func StrictILike(db *gorm.DB, column, value string) *gorm.DB {
+	if value == "" {
+		return db
+	}
+	col, err := resolveColumn(db, column)
+	if err != nil {
+		return addError(db, &IdentifierError{Clause: "ILIKE", Identifier: column, Err: err})
+	}
+	return db.Where(clause.Expr{SQL: "? ILIKE ?", Vars: []any{col, "%" + value + "%"}})
+}
+
+// StrictWhere matches the records whose column satisfies condition, the SQL following the
+// column with placeholders for vars e.g " BETWEEN ? AND ?", accepting only a column of the
+// queried model. See StrictOrder.
+func StrictWhere(db *gorm.DB, column, condition string, vars ...any) *gorm.DB {
+	col, err := resolveColumn(db, column)
+	if err != nil {
+		return addError(db, &IdentifierError{Clause: "WHERE", Identifier: column, Err: err})
+	}
+	return db.Where(clause.Expr{SQL: "?" + condition, Vars: append([]any{col}, vars...)})
+}
+
+// addError fails the query of db with err, leaving db as is.
+func addError(db *gorm.DB, err error) *gorm.DB {
+	db = db.Session(&gorm.Session{})
+	_ = db.AddError(err)
+	return db
+}
+
+// resolveColumn returns the column named by name in the model queried by db: a column
+// or field of the model, optionally prefixed with its table, or a relation path ending
+// with a column or field of the related model.
+func resolveColumn(db *gorm.DB, name string) (clause.Column, error) {
+	model, ok := db.Get(modelKey)
+	var hidden []string
+	if ok {
+		hidden = model.(scopeModel).hidden
+		model = model.(scopeModel).value
+	} else if model = db.Statement.Model; model == nil {
+		return clause.Column{}, ErrUnknownModel
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return clause.Column{}, err
+	}
+	sch := stmt.Schema
+
+	path := strings.Split(name, ".")
+	if len(path) == 2 && (path[0] == sch.Table || path[0] != "" && path[0] == db.Statement.Table) {
+		path = path[1:]
+	}
+
+	table := clause.CurrentTable
+	for i, relationName := range path[:len(path)-1] {
+		relation, ok := sch.Relationships.Relations[relationName]
+		if !ok {
+			return clause.Column{}, ErrUnknownField
+		}
+		sch, hidden = relation.FieldSchema, nil
+		if i == 0 {
+			table = relationName
+		} else {
+			// Nested relations are joined with aliases like Role__Permissions.
+			table += "__" + relationName
+		}
+	}
+
+	field := sch.LookUpField(path[len(path)-1])
+	if field == nil || field.DBName == "" || slices.Contains(hidden, field.DBName) {
+		return clause.Column{}, ErrUnknownField
+	}
+	return clause.Column{Table: table, Name: field.DBName}, nil
+}
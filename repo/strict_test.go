@@ -0,0 +1,79 @@
+package repo
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestStrictScopes(t *testing.T) {
+	db, statements := dryRun(t)
+	users := New(db, userMeta)
+
+	_, err := users.FindMany(
+		func(db *gorm.DB) *gorm.DB { return db.Joins("Role") },
+		func(db *gorm.DB) *gorm.DB { return StrictSelect(db, "id, Name", "Role.name") },
+		func(db *gorm.DB) *gorm.DB { return StrictILike(db, "app_users.name", "jo") },
+		func(db *gorm.DB) *gorm.DB { return StrictWhere(db, "RoleID", " BETWEEN ? AND ?", 1, 3) },
+		func(db *gorm.DB) *gorm.DB { return StrictGroup(db, "id,Role.ID") },
+		func(db *gorm.DB) *gorm.DB { return StrictOrder(db, "Role.Name desc, role_id") },
+		func(db *gorm.DB) *gorm.DB { return StrictILike(db, "secret", "") },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, stmt := range statements() {
+		got = append(got, stmt.SQL)
+	}
+	want := []string{
+		"SELECT `id`,`name`,`Role`.`name`,`Role`.`id` AS `Role__id`,`Role`.`name` AS `Role__name` FROM `app_users` LEFT JOIN `roles` `Role` ON `app_users`.`role_id` = `Role`.`id` " +
+			"WHERE `app_users`.`name` ILIKE ? AND (`app_users`.`role_id` BETWEEN ? AND ?) GROUP BY `app_users`.`id`,`Role`.`id` ORDER BY `Role`.`name` DESC,`app_users`.`role_id`",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected statements\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestStrictScopesErrors(t *testing.T) {
+	db, statements := dryRun(t)
+	users := New(db, userMeta)
+
+	tests := []struct {
+		scope      Scope
+		identifier string
+		unknown    bool
+	}{
+		{func(db *gorm.DB) *gorm.DB { return StrictOrder(db, "name; DROP TABLE users") }, "name; DROP TABLE users", true},
+		{func(db *gorm.DB) *gorm.DB { return StrictOrder(db, "id, name DROP") }, "name DROP", false},
+		{func(db *gorm.DB) *gorm.DB { return StrictSelect(db, "id", "count(*)") }, "count(*)", true},
+		{func(db *gorm.DB) *gorm.DB { return StrictSelect(db, "password") }, "password", true}, // hidden
+		{func(db *gorm.DB) *gorm.DB { return StrictGroup(db, "Team.name") }, "Team.name", true},
+		{func(db *gorm.DB) *gorm.DB { return StrictILike(db, "lower(name)", "jo") }, "lower(name)", true},
+		{func(db *gorm.DB) *gorm.DB { return StrictWhere(db, "DATE(id)", " >= ?", 1) }, "DATE(id)", true},
+	}
+	for _, tt := range tests {
+		_, err := users.FindMany(tt.scope)
+
+		var identifierErr *IdentifierError
+		if !errors.As(err, &identifierErr) || identifierErr.Identifier != tt.identifier {
+			t.Errorf("expected an *IdentifierError for %q, got %v", tt.identifier, err)
+		}
+		if errors.Is(err, ErrUnknownField) != tt.unknown {
+			t.Errorf("expected ErrUnknownField to be %v for %q, got %v", tt.unknown, tt.identifier, err)
+		}
+	}
+	for _, stmt := range statements() {
+		if stmt.SQL != "" {
+			t.Errorf("expected no query to be built, got %s", stmt.SQL)
+		}
+	}
+
+	if err := StrictOrder(db, "id").Find(&[]User{}).Error; !errors.Is(err, ErrUnknownModel) {
+		t.Errorf("expected ErrUnknownModel without a model, got %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrNoTenant is the error of the queries of models with the tenant column of UseTenancy
+// run with a context holding no tenant. See WithTenant and WithoutTenant.
+var ErrNoTenant = errors.New("repo: query of a tenant scoped model has no tenant in its context")
+
+// tenancyCallback is the name of the callbacks and plugin registered by UseTenancy.
+const tenancyCallback = "repo:tenancy"
+
+// tenancy is the gorm.Plugin of UseTenancy recording its column.
+type tenancy struct{ column string }
+
+// Name returns the name of the plugin.
+func (*tenancy) Name() string {
+	return tenancyCallback
+}
+
+// Initialize registers the callbacks scoping the queries of db to their tenant.
+func (t *tenancy) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	scope := func(db *gorm.DB) { scopeTenant(db, t.column, false) }
+	scopeWrite := func(db *gorm.DB) { scopeTenant(db, t.column, true) }
+	for _, err := range []error{
+		callbacks.Create().Before("gorm:create").Register(tenancyCallback, func(db *gorm.DB) { setTenant(db, t.column) }),
+		callbacks.Query().Before("gorm:query").Register(tenancyCallback, scope),
+		callbacks.Row().Before("gorm:row").Register(tenancyCallback, scope),
+		callbacks.Update().Before("gorm:update").Register(tenancyCallback, scopeWrite),
+		callbacks.Delete().Before("gorm:delete").Register(tenancyCallback, scopeWrite),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tenantKey is the context key of the tenant of WithTenant.
+type tenantKey struct{}
+
+// allTenants is the tenant of the contexts of WithoutTenant.
+type allTenants struct{}
+
+// WithTenant returns a copy of ctx whose queries are scoped to tenant, the value of the
+// tenant column e.g an organization id. See UseTenancy.
+func WithTenant(ctx context.Context, tenant any) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// WithoutTenant returns a copy of ctx whose queries are not scoped to a tenant, for admin
+// code reading or writing the records of all tenants.
+func WithoutTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tenantKey{}, allTenants{})
+}
+
+// Tenant returns the tenant of ctx set with WithTenant, false if none.
+func Tenant(ctx context.Context) (any, bool) {
+	tenant := ctx.Value(tenantKey{})
+	if _, all := tenant.(allTenants); all || tenant == nil {
+		return nil, false
+	}
+	return tenant, true
+}
+
+// tenantOf returns the tenant scoping the queries run with ctx, false for the contexts
+// of WithoutTenant and ErrNoTenant if ctx has no tenant.
+func tenantOf(ctx context.Context) (any, bool, error) {
+	switch tenant := ctx.Value(tenantKey{}).(type) {
+	case nil:
+		return nil, false, ErrNoTenant
+	case allTenants:
+		return nil, false, nil
+	default:
+		return tenant, true, nil
+	}
+}
+
+// UseTenancy scopes the queries of db on the models with column to the tenant of their
+// context: reads, counts, preloads, updates and deletes only match the rows of the tenant,
+// creates set column to the tenant and updates never change it. Queries fail with
+// ErrNoTenant if their context has neither WithTenant nor WithoutTenant. Raw SQL and the
+// tables joined with gorm.DB.Joins are not scoped.
+//
+// The scoping applies to every gorm.DB sharing the callbacks of db. Registering it again
+// with the same column does nothing, with another column fails.
+func UseTenancy(db *gorm.DB, column string) error {
+	if plugin, ok := db.Config.Plugins[tenancyCallback]; ok {
+		if registered := plugin.(*tenancy).column; registered != column {
+			return fmt.Errorf("repo: tenancy already uses the column %q, not %q", registered, column)
+		}
+		return nil
+	}
+	return db.Use(&tenancy{column: column})
+}
+
+// tenantField returns the field of column in the model of the statement of db and the tenant
+// of its context, false if the statement is not scoped. Fails the statement without a tenant.
+func tenantField(db *gorm.DB, column string) (*schema.Field, any, bool) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return nil, nil, false
+	}
+	field := db.Statement.Schema.LookUpField(column)
+	if field == nil || field.DBName == "" {
+		return nil, nil, false
+	}
+
+	tenant, scoped, err := tenantOf(db.Statement.Context)
+	if err != nil {
+		_ = db.AddError(err)
+	}
+	return field, tenant, scoped
+}
+
+// scopeTenant adds the tenant condition to the statement of db. Writes leave the column
+// as is, and statements with no condition to GORM, that matches the primary key of the
+// record or fails with gorm.ErrMissingWhereClause.
+func scopeTenant(db *gorm.DB, column string, write bool) {
+	field, tenant, ok := tenantField(db, column)
+	if !ok {
+		return
+	}
+
+	stmt := db.Statement
+	if write {
+		if _, where := stmt.Clauses["WHERE"]; !where && !db.AllowGlobalUpdate {
+			_, keys := schema.GetIdentityFieldValuesMap(stmt.Context, stmt.ReflectValue, stmt.Schema.PrimaryFields)
+			if len(keys) == 0 {
+				return
+			}
+		}
+		stmt.Omits = append(stmt.Omits, field.DBName)
+	}
+	stmt.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: tenant},
+	}})
+}
+
+// setTenant sets the tenant column of the records created by db.
+func setTenant(db *gorm.DB, column string) {
+	field, tenant, ok := tenantField(db, column)
+	if !ok {
+		return
+	}
+
+	set := func(record reflect.Value) {
+		switch record = reflect.Indirect(record); record.Kind() {
+		case reflect.Struct:
+			_ = db.AddError(field.Set(db.Statement.Context, record, tenant))
+		case reflect.Map:
+			if values, ok := record.Interface().(map[string]any); ok {
+				values[field.DBName] = tenant
+			}
+		}
+	}
+
+	switch value := reflect.Indirect(reflect.ValueOf(db.Statement.Dest)); value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := range value.Len() {
+			set(value.Index(i))
+		}
+	default:
+		set(value)
+	}
+}
@@ -0,0 +1,148 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Project struct {
+	ID       uint
+	TenantID uint
+	Name     string
+	Tasks    []Task
+}
+
+type Task struct {
+	ID        uint
+	TenantID  uint
+	ProjectID uint
+	Title     string
+}
+
+var projectMeta = &Meta[Project, uint]{
+	Preloads:      []string{"Tasks"},
+	Omit:          []string{"Tasks"},
+	Tenant:        "tenant_id",
+	PrimaryKey:    func(p *Project) uint { return p.ID },
+	SetPrimaryKey: func(p *Project, id uint) { p.ID = id },
+	KeyCondition: func(keys ...uint) clause.Expression {
+		return clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: "id"}, Value: keys[0]}
+	},
+}
+
+// tenantDB returns a dry run database scoped with UseTenancy whose queries of projects
+// find a project and updates match a row.
+func tenantDB(t *testing.T) (*gorm.DB, func() []statement) {
+	t.Helper()
+	db, statements := dryRun(t)
+	if err := UseTenancy(db, "tenant_id"); err != nil {
+		t.Fatal(err)
+	}
+	if err := UseTenancy(db, "tenant_id"); err != nil {
+		t.Fatalf("expected registering twice to do nothing, got %v", err)
+	}
+	if err := UseTenancy(db.Session(&gorm.Session{}), "account_id"); err == nil || !strings.Contains(err.Error(), `"tenant_id"`) {
+		t.Fatalf("expected registering another column to fail, got %v", err)
+	}
+
+	err := db.Callback().Query().After("gorm:query").Before("gorm:preload").Register("test:rows", func(tx *gorm.DB) {
+		if projects, ok := tx.Statement.Dest.(*[]Project); ok {
+			*projects, tx.RowsAffected = []Project{{ID: 1, TenantID: 7}}, 1
+		}
+	})
+	if err == nil {
+		err = db.Callback().Update().After("gorm:update").Register("test:rows", func(tx *gorm.DB) {
+			tx.RowsAffected = 1
+		})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, statements
+}
+
+func TestTenancy(t *testing.T) {
+	db, statements := tenantDB(t)
+	projects := New(db, projectMeta).WithContext(WithTenant(context.Background(), uint(7)))
+
+	if _, err := projects.PreloadAll(true).FindMany(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := projects.Count(); err != nil {
+		t.Fatal(err)
+	}
+	project := &Project{Name: "apigen", TenantID: 8}
+	if err := projects.Create(project); err != nil {
+		t.Fatal(err)
+	}
+	if project.TenantID != 7 {
+		t.Errorf("expected Create to set the tenant 7, got %d", project.TenantID)
+	}
+	if _, err := projects.Update(1, &Project{Name: "renamed", TenantID: 8}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := projects.PartialUpdateWithMap(1, map[string]any{"tenant_id": 8, "name": "moved"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := projects.Upsert(&Project{ID: 1, Name: "upserted"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := projects.Delete(1); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, stmt := range statements() {
+		got = append(got, stmt.SQL)
+	}
+	want := []string{
+		"SELECT * FROM `tasks` WHERE `tasks`.`project_id` = ? AND `tasks`.`tenant_id` = ?",
+		"SELECT * FROM `projects` WHERE `projects`.`tenant_id` = ?",
+		"SELECT count(*) FROM `projects` WHERE `projects`.`tenant_id` = ?",
+		"INSERT INTO `projects` (`tenant_id`,`name`) VALUES (?,?) RETURNING `id`",
+		"UPDATE `projects` SET `name`=? WHERE `projects`.`tenant_id` = ? AND `id` = ?",
+		"UPDATE `projects` SET `name`=? WHERE `projects`.`id` = ? AND `projects`.`tenant_id` = ?",
+		"SELECT * FROM `projects` WHERE `projects`.`id` = ? AND `projects`.`tenant_id` = ? ORDER BY `projects`.`id` LIMIT ?",
+		"INSERT INTO `projects` (`tenant_id`,`name`,`id`) VALUES (?,?,?) ON CONFLICT (`id`) DO UPDATE SET `name`=`excluded`.`name` WHERE `projects`.`tenant_id` = `excluded`.`tenant_id`  RETURNING `id`",
+		"DELETE FROM `projects` WHERE `projects`.`id` = ? AND `projects`.`tenant_id` = ?",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected statements\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestTenancyRequiresTenant(t *testing.T) {
+	db, statements := tenantDB(t)
+	projects := New(db, projectMeta)
+
+	if _, err := projects.FindMany(); !errors.Is(err, ErrNoTenant) {
+		t.Errorf("expected ErrNoTenant without a tenant, got %v", err)
+	}
+	if err := projects.Create(&Project{}); !errors.Is(err, ErrNoTenant) {
+		t.Errorf("expected ErrNoTenant without a tenant, got %v", err)
+	}
+	for _, stmt := range statements() {
+		if stmt.SQL != "" {
+			t.Errorf("expected no statement to be built, got %s", stmt.SQL)
+		}
+	}
+
+	// Models without the tenant column and admin code are not scoped.
+	if _, err := New(db, userMeta).FindMany(); err != nil {
+		t.Errorf("expected models without the tenant column not to be scoped, got %v", err)
+	}
+	if _, err := projects.WithContext(WithoutTenant(context.Background())).PreloadAll(false).FindMany(); err != nil {
+		t.Fatal(err)
+	}
+	got := statements()
+	want := []string{"SELECT `app_users`.`id`,`app_users`.`name`,`app_users`.`role_id` FROM `app_users`", "SELECT * FROM `projects`"}
+	if len(got) < 2 || got[len(got)-2].SQL != want[0] || got[len(got)-1].SQL != want[1] {
+		t.Errorf("expected statements\n%s\ngot %v", strings.Join(want, "\n"), got)
+	}
+}
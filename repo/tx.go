@@ -0,0 +1,63 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+// retryableStates are the SQLSTATE codes of transactions worth retrying:
+// serialization_failure and deadlock_detected.
+var retryableStates = []string{"40001", "40P01"}
+
+// TxOptions configures Transaction.
+type TxOptions struct {
+	// Attempts is the number of times a transaction failing with a serialization failure
+	// or a deadlock (SQLSTATE 40001 or 40P01) is run, 1 by default. Nested transactions are
+	// never retried on their own as the whole transaction has to be.
+	Attempts int
+
+	// TxOptions holds the isolation level and read only mode of the transaction.
+	TxOptions *sql.TxOptions
+}
+
+// Transaction runs fn with a db whose queries run in a transaction of db with ctx. The
+// transaction is committed if fn returns nil and rolled back if fn returns an error or
+// panics, the panic being propagated. Within a transaction, fn runs in a SAVEPOINT rolled
+// back on error instead, leaving the enclosing transaction to commit or roll back.
+// Failed transactions are retried as configured by opts, fn must be safe to rerun.
+func Transaction(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error, opts ...*TxOptions) error {
+	var options TxOptions
+	if len(opts) > 0 && opts[0] != nil {
+		options = *opts[0]
+	}
+
+	db = db.WithContext(ctx)
+	if _, nested := db.Statement.ConnPool.(gorm.TxCommitter); nested {
+		return db.Transaction(fn)
+	}
+
+	var sqlOptions []*sql.TxOptions
+	if options.TxOptions != nil {
+		sqlOptions = append(sqlOptions, options.TxOptions)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := db.Transaction(fn, sqlOptions...)
+		if err == nil || attempt >= options.Attempts || !IsRetryable(err) || ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+// IsRetryable reports whether err is a serialization failure or a deadlock
+// (SQLSTATE 40001 or 40P01) after which the transaction can be run again.
+// Errors of drivers reporting their SQLSTATE with a SQLState method are
+// recognized, like those of pgx and lib/pq.
+func IsRetryable(err error) bool {
+	var stateErr interface{ SQLState() string }
+	return errors.As(err, &stateErr) && slices.Contains(retryableStates, stateErr.SQLState())
+}
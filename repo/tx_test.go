@@ -0,0 +1,140 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/utils/tests"
+)
+
+// txLog records the transaction statements of a txDialector and its txPool.
+type txLog struct{ statements []string }
+
+func (l *txLog) add(statement string) error {
+	l.statements = append(l.statements, statement)
+	return nil
+}
+
+// txDialector is a dialector supporting savepoints.
+type txDialector struct {
+	tests.DummyDialector
+	log *txLog
+}
+
+func (d txDialector) SavePoint(tx *gorm.DB, name string) error { return d.log.add("SAVEPOINT") }
+func (d txDialector) RollbackTo(tx *gorm.DB, name string) error {
+	return d.log.add("ROLLBACK TO SAVEPOINT")
+}
+
+// txPool is a connection pool beginning transactions that run no query.
+type txPool struct {
+	gorm.ConnPool
+	log *txLog
+}
+
+func (p *txPool) BeginTx(context.Context, *sql.TxOptions) (gorm.ConnPool, error) {
+	return (*txConn)(p), p.log.add("BEGIN")
+}
+
+type txConn txPool
+
+func (c *txConn) Commit() error   { return c.log.add("COMMIT") }
+func (c *txConn) Rollback() error { return c.log.add("ROLLBACK") }
+
+// sqlStateError is a driver error with a SQLSTATE.
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "sqlstate " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+func txDB(t *testing.T) (*gorm.DB, *txLog) {
+	t.Helper()
+	log := &txLog{}
+	db, err := gorm.Open(txDialector{log: log}, &gorm.Config{ConnPool: &txPool{log: log}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, log
+}
+
+func TestTransaction(t *testing.T) {
+	ctx := context.Background()
+	failed := errors.New("failed")
+
+	tests := []struct {
+		name string
+		fn   func(tx *gorm.DB) error
+		err  error
+		want []string
+	}{
+		{"commit", func(*gorm.DB) error { return nil }, nil, []string{"BEGIN", "COMMIT"}},
+		{"rollback", func(*gorm.DB) error { return failed }, failed, []string{"BEGIN", "ROLLBACK"}},
+		{
+			"savepoint",
+			func(tx *gorm.DB) error {
+				if err := Transaction(ctx, tx, func(*gorm.DB) error { return failed }); !errors.Is(err, failed) {
+					t.Errorf("expected the nested transaction error, got %v", err)
+				}
+				return Transaction(ctx, tx, func(*gorm.DB) error { return nil })
+			},
+			nil,
+			[]string{"BEGIN", "SAVEPOINT", "ROLLBACK TO SAVEPOINT", "SAVEPOINT", "COMMIT"},
+		},
+	}
+	for _, tt := range tests {
+		db, log := txDB(t)
+		if err := Transaction(ctx, db, tt.fn); !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.err, err)
+		}
+		if !slices.Equal(log.statements, tt.want) {
+			t.Errorf("%s: expected %s, got %s", tt.name, strings.Join(tt.want, ", "), strings.Join(log.statements, ", "))
+		}
+	}
+}
+
+func TestTransactionPanic(t *testing.T) {
+	db, log := txDB(t)
+	defer func() {
+		if recover() == nil {
+			t.Error("expected the panic to be propagated")
+		}
+		if want := []string{"BEGIN", "ROLLBACK"}; !slices.Equal(log.statements, want) {
+			t.Errorf("expected %s, got %s", strings.Join(want, ", "), strings.Join(log.statements, ", "))
+		}
+	}()
+	_ = Transaction(context.Background(), db, func(*gorm.DB) error { panic("boom") })
+}
+
+func TestTransactionRetry(t *testing.T) {
+	db, log := txDB(t)
+
+	var runs int
+	err := Transaction(context.Background(), db, func(*gorm.DB) error {
+		runs++
+		if runs < 3 {
+			return sqlStateError([]string{"40001", "40P01"}[runs-1])
+		}
+		return nil
+	}, &TxOptions{Attempts: 3})
+	if err != nil || runs != 3 {
+		t.Fatalf("expected the transaction to succeed on the third attempt, got %d runs and %v", runs, err)
+	}
+	want := []string{"BEGIN", "ROLLBACK", "BEGIN", "ROLLBACK", "BEGIN", "COMMIT"}
+	if !slices.Equal(log.statements, want) {
+		t.Errorf("expected %s, got %s", strings.Join(want, ", "), strings.Join(log.statements, ", "))
+	}
+
+	runs = 0
+	err = Transaction(context.Background(), db, func(*gorm.DB) error {
+		runs++
+		return sqlStateError("23505") // unique_violation
+	}, &TxOptions{Attempts: 3})
+	if runs != 1 || IsRetryable(err) {
+		t.Errorf("expected other errors not to be retried, got %d runs and %v", runs, err)
+	}
+}
@@ -3,6 +3,7 @@ package typescript
 import (
 	"fmt"
 	"io"
+	"maps"
 	"regexp"
 	"strings"
 
@@ -24,102 +25,150 @@ func getJSONFieldName(tag string) string {
 	return ""
 }
 
-// Helper recursive method to generate the typescript types.
-// If recursive, do not recreate the override types.
+// Helper method to generate the typescript types.
 func generateInterfaces(
 	output io.Writer,
 	inputs map[string]parser.StructMeta,
 	overrides config.Overrides,
-	recursive bool,
 	generated map[string]bool,
 ) {
-	// Code to generate only once.
-	if !recursive {
-		// Create custom override types
-		for key, value := range overrides.Types {
-			fmt.Fprintf(output, "type %s = %s\n\n", key, value)
-		}
+	// Create custom override types
+	for key, value := range overrides.Types {
+		fmt.Fprintf(output, "type %s = %s\n\n", key, value)
 	}
 
 	for _, input := range inputs {
-		// skip structs with empty fields
-		if len(input.Fields) == 0 {
-			continue
-		}
+		writeInterface(output, input, inputs, overrides, generated)
+	}
+}
+
+// writeInterface writes the interface for input to output, recursively writing
+// the interfaces of the structs in inputs that it references first.
+func writeInterface(
+	output io.Writer,
+	input parser.StructMeta,
+	inputs map[string]parser.StructMeta,
+	overrides config.Overrides,
+	generated map[string]bool,
+) {
+	// skip structs with empty fields
+	if len(input.Fields) == 0 {
+		return
+	}
+
+	// Check if already generated
+	if _, exists := generated[input.Name]; exists {
+		return
+	}
 
-		// Check if already generated
-		if _, exists := generated[input.Name]; exists {
+	// Mark as generated here before calling recursive functions below
+	// Otherwise, produces infinite recursion and panics.
+	generated[input.Name] = true
+
+	builder := strings.Builder{}
+	if input.Table != "" {
+		// Document the table resolved with the configured naming strategy.
+		fmt.Fprintf(&builder, "/** Table: %s */\n", input.Table)
+	}
+	builder.WriteString(`interface `)
+	builder.WriteString(input.Name)
+	builder.WriteString(" {\n")
+
+	for _, f := range input.Fields {
+		// Columns of named embedded structs are nested under their embedding field in JSON.
+		// Hidden fields like passwords are never sent to clients.
+		if f.EmbeddedPath != "" || f.Hidden {
 			continue
 		}
 
-		// Mark as generated here before calling recursive functions below
-		// Otherwise, produces infinite recursion and panics.
-		generated[input.Name] = true
-
-		builder := strings.Builder{}
-		builder.WriteString(`interface `)
-		builder.WriteString(input.Name)
-		builder.WriteString(" {\n")
+		fieldName := getJSONFieldName(f.Tag)
 
-		for _, f := range input.Fields {
-			fieldName := getJSONFieldName(f.Tag)
+		// Fields with tag name - are skipped by json encoder
+		if fieldName == "-" {
+			continue
+		}
 
-			// Fields with tag name - are skipped by json encoder
-			if fieldName == "-" {
-				continue
-			}
+		// If json tag is missing, use exact FieldName
+		if fieldName == "" {
+			fieldName = f.Name
+		}
 
-			// If json tag is missing, use exact FieldName
-			if fieldName == "" {
-				fieldName = f.Name
-			}
+		// Add field to interface
+		builder.WriteRune('\t')
+		if input.ReadOnly {
+			// Models of SQL views or marked with apigen:readonly can't be written.
+			builder.WriteString("readonly ")
+		}
+		builder.WriteString(fieldName)
 
-			// Add field to interface
-			builder.WriteRune('\t')
-			builder.WriteString(fieldName)
+		// Check if there is an override for this field
+		if overrideType, ok := overrides.Fields[fieldName]; ok {
+			builder.WriteString(": ")
+			builder.WriteString(overrideType + ";\n")
+			continue
+		}
 
-			// Check if there is an override for this field
-			if overrideType, ok := overrides.Fields[fieldName]; ok {
+		if f.Kind == parser.KindSlice || f.Kind == parser.KindArray {
+			// Check if the element type of the slice or array references a struct in the inputs map
+			if structMeta, ok := inputs[f.ElemType]; ok {
+				writeInterface(output, structMeta, inputs, overrides, generated)
+				builder.WriteString(": ")
+				builder.WriteString(structMeta.Name)
+				builder.WriteString("[];")
+			} else {
 				builder.WriteString(": ")
-				builder.WriteString(overrideType + ";\n")
-				continue
+				builder.WriteString(tsType(f, overrides))
+				builder.WriteString(";")
 			}
-
-			// Ignore pointer
-			f.Type = strings.TrimPrefix(f.Type, "*")
-
-			if strings.HasPrefix(f.Type, "[]") || strings.HasPrefix(f.Type, "[") {
-				// Get the element type of the slice or array
-				elementType := f.Type[strings.IndexByte(f.Type, ']')+1:]
-
-				if input, ok := inputs[elementType]; ok {
-					generateInterfaces(output,
-						map[string]parser.StructMeta{input.Name: input}, overrides, true, generated)
-					builder.WriteString(": ")
-					builder.WriteString(input.Name)
-					builder.WriteString("[];")
-				} else {
-					builder.WriteString(": ")
-					writeSliceType(&builder, elementType)
-				}
+		} else {
+			// Check if f.BaseType references a struct in the inputs map
+			if structMeta, ok := inputs[f.BaseType]; ok {
+				// Recursively generate interface for referenced struct
+				writeInterface(output, structMeta, inputs, overrides, generated)
+				builder.WriteString(": ")
+				builder.WriteString(structMeta.Name)
 			} else {
-				// Check if f.Type references a struct in the inputs map
-				if structMeta, ok := inputs[f.Type]; ok {
-					// Recursively generate interface for referenced struct
-					generateInterfaces(output,
-						map[string]parser.StructMeta{f.Type: structMeta}, overrides, true, generated)
-					builder.WriteString(": ")
-					builder.WriteString(structMeta.Name)
-					builder.WriteString(";")
-				} else {
-					writeFieldType(&builder, f.Type)
-				}
+				builder.WriteString(": ")
+				builder.WriteString(tsType(f, overrides))
 			}
-			builder.WriteString("\n")
+			writeNullable(&builder, f)
+			builder.WriteString(";")
+		}
+		builder.WriteString("\n")
+	}
+	builder.WriteString("}\n\n")
+	_, _ = output.Write([]byte(builder.String()))
+}
+
+// WithEnums returns overrides with a union type for each enum e.g Sex = "Male" | "Female".
+// Types already in overrides are kept as is. Typescript types are named without their
+// package, so enums with the same name in several packages get no union and a warning:
+// their fields are typed by their kind unless the name has a type override.
+func WithEnums(overrides config.Overrides, enums []parser.Enum) (config.Overrides, []parser.Diagnostic) {
+	types := make(map[string]string, len(overrides.Types)+len(enums))
+	packages := make(map[string]string, len(enums)) // Package of the first enum of each name
+	var diagnostics []parser.Diagnostic
+	for _, e := range enums {
+		if _, ok := overrides.Types[e.Name]; ok {
+			continue
+		}
+		if pkg, ok := packages[e.Name]; ok {
+			delete(types, e.Name)
+			diagnostics = append(diagnostics, parser.Diagnostic{
+				Pos:      e.Pos,
+				Severity: parser.SeverityWarning,
+				Message: fmt.Sprintf("enum %s of %s clashes with the one of %s in typescript, add a type override for it",
+					e.Name, e.Package, pkg),
+			})
+			continue
 		}
-		builder.WriteString("}\n\n")
-		_, _ = output.Write([]byte(builder.String()))
+		packages[e.Name] = e.Package
+		types[e.Name] = e.TypescriptUnion()
 	}
+	maps.Copy(types, overrides.Types)
+
+	overrides.Types = types
+	return overrides, diagnostics
 }
 
 // Generate typescript interfaces given the map of parser StructMeta.
@@ -130,45 +179,83 @@ func GenerateTypescriptInterfaces(
 	overrides config.Overrides,
 ) {
 	generated := make(map[string]bool)
-	generateInterfaces(output, inputs, overrides, false, generated)
+	generateInterfaces(output, inputs, overrides, generated)
 }
 
-// Write typescript field type based on fieldType to builder.
-// Derived types can not be inferred, in which case it return writeFieldType unmodified.
+// knownTypes maps named Go types that are neither basic nor TextMarshalers to
+// the typescript type matching their JSON encoding.
+// Keys are the import path and the type name e.g "gorm.io/gorm.DeletedAt".
+var knownTypes = map[string]string{
+	"gorm.io/gorm.DeletedAt":          "string | null",
+	"github.com/google/uuid.NullUUID": "string | null",
+	"database/sql.NullString":         "string | null",
+	"database/sql.NullInt64":          "number | null",
+	"database/sql.NullInt32":          "number | null",
+	"database/sql.NullInt16":          "number | null",
+	"database/sql.NullByte":           "number | null",
+	"database/sql.NullFloat64":        "number | null",
+	"database/sql.NullBool":           "boolean | null",
+	"database/sql.NullTime":           "string | null",
+}
+
+// tsType returns the typescript type of field f resolved from its underlying kind.
 //
 //	e.g type Sex string
 //
-// When a struct is created with field Sex, we are unable to infer underlying type as string.
-// Feel free to submit a pull request addressing this issue.
-func writeFieldType(builder *strings.Builder, fieldType string) {
-	switch fieldType {
-	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64",
-		"float32", "float64":
-		builder.WriteString(": number;")
-	case "string":
-		builder.WriteString(": string;")
-	case "bool":
-		builder.WriteString(": boolean;")
-	case "time.Time":
-		builder.WriteString(": string;")
-	default:
-		builder.WriteString(": ")
-		builder.WriteString(fieldType)
+// A field of type Sex is written as string unless Sex has a type override.
+func tsType(f parser.Field, overrides config.Overrides) string {
+	if f.Kind == parser.KindSlice || f.Kind == parser.KindArray {
+		// []byte is encoded as a base64 string by encoding/json. Byte arrays are not.
+		if f.Kind == parser.KindSlice && f.ElemKind == parser.KindUint && (f.ElemType == "byte" || f.ElemType == "uint8") {
+			return "string"
+		}
+
+		// Named arrays like uuid.UUID marshal themselves as strings.
+		if f.TextMarshaler {
+			return "string"
+		}
+		elem := scalarType(f.ElemType, f.PkgPath, f.ElemKind, false, overrides)
+		if strings.Contains(elem, " ") {
+			elem = "(" + elem + ")"
+		}
+		return elem + "[]"
 	}
+	return scalarType(f.BaseType, f.PkgPath, f.Kind, f.TextMarshaler, overrides)
 }
 
-// Writes the slice type by switching on the elementType.
-func writeSliceType(builder *strings.Builder, elemType string) {
-	switch elemType {
-	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64",
-		"float32", "float64":
-		builder.WriteString("number[];")
-	case "string":
-		builder.WriteString("string[];")
-	case "bool":
-		builder.WriteString("boolean[];")
+// scalarType returns the typescript type for a non-collection Go type given its name
+// relative to the model package, the import path declaring it and its underlying kind.
+func scalarType(typeName, pkgPath string, kind parser.Kind, textMarshaler bool, overrides config.Overrides) string {
+	if _, ok := overrides.Types[typeName]; ok {
+		return typeName
+	}
+
+	if pkgPath != "" {
+		name := typeName[strings.LastIndexByte(typeName, '.')+1:]
+		if known, ok := knownTypes[pkgPath+"."+name]; ok {
+			return known
+		}
+	}
+
+	if textMarshaler {
+		return "string"
+	}
+
+	switch kind {
+	case parser.KindInt, parser.KindUint, parser.KindFloat:
+		return "number"
+	case parser.KindString:
+		return "string"
+	case parser.KindBool:
+		return "boolean"
 	default:
-		builder.WriteString(elemType)
-		builder.WriteString("[];")
+		return "any"
+	}
+}
+
+// writeNullable marks pointer and NULL-able fields as nullable.
+func writeNullable(builder *strings.Builder, f parser.Field) {
+	if f.Nullable && !strings.HasSuffix(builder.String(), "null") {
+		builder.WriteString(" | null")
 	}
 }
@@ -0,0 +1,121 @@
+package typescript
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/abiiranathan/apigen/config"
+	"github.com/abiiranathan/apigen/parser"
+)
+
+func TestPrimaryKeyTypes(t *testing.T) {
+	structs, _, err := parser.Parse([]string{"../parser/testdata/models"}, nil)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	inputs := parser.Map(structs)
+
+	var buf bytes.Buffer
+	GenerateTypescriptInterfaces(&buf, inputs, config.Overrides{})
+	out := buf.String()
+
+	for _, want := range []string{
+		"/** Table: devices */\ninterface Device {\n\tid: string;\n", // ids.UUID implements encoding.TextMarshaler
+		"interface Tag {\n\tid: string;\n",                           // string ID
+		"interface Patient {\n\tid: number;\n",                       // type PatientID int64
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q\nGot:\n%s", want, out)
+		}
+	}
+}
+
+func TestNullableTypes(t *testing.T) {
+	structs, _, err := parser.Parse([]string{"../parser/testdata/models"}, nil)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	inputs := parser.Map(structs)
+
+	var buf bytes.Buffer
+	GenerateTypescriptInterfaces(&buf, inputs, config.Overrides{})
+	out := buf.String()
+
+	for _, want := range []string{
+		"\tnickname: string | null;\n", // *string
+		"\tphone: string | null;\n",    // sql.NullString
+		"\tdeleted_at: string | null;\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q\nGot:\n%s", want, out)
+		}
+	}
+}
+
+func TestDirectives(t *testing.T) {
+	structs, _, err := parser.Parse([]string{"../parser/testdata/models"}, nil)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	inputs := parser.Map(structs)
+
+	var buf bytes.Buffer
+	GenerateTypescriptInterfaces(&buf, inputs, config.Overrides{})
+	out := buf.String()
+
+	if !strings.Contains(out, "/** Table: club_members */\ninterface Member {\n\tid: number;\n\trole_id: number;\n") {
+		t.Errorf("expected the apigen:table name on Member\nGot:\n%s", out)
+	}
+	if strings.Contains(out, "password") {
+		t.Errorf("expected apigen:hidden field to be omitted\nGot:\n%s", out)
+	}
+	if !strings.Contains(out, "interface MemberStats {\n\treadonly member_id: number;\n\treadonly member: Member;\n") {
+		t.Errorf("expected apigen:readonly fields to be readonly\nGot:\n%s", out)
+	}
+}
+
+func TestWithEnums(t *testing.T) {
+	enums := []parser.Enum{
+		{Name: "Status", Kind: parser.KindString, Values: []parser.EnumValue{{Name: "StatusActive", Value: `"active"`}, {Name: "StatusInactive", Value: `"inactive"`}}},
+		{Name: "Sex", Kind: parser.KindString, Values: []parser.EnumValue{{Name: "Male", Value: `"Male"`}}},
+	}
+	overrides := config.Overrides{Types: map[string]string{"Sex": `"M" | "F"`}}
+
+	got, diagnostics := WithEnums(overrides, enums)
+	if len(diagnostics) != 0 {
+		t.Errorf("expected no diagnostics, got %v", diagnostics)
+	}
+	if got.Types["Status"] != `"active" | "inactive"` {
+		t.Errorf("expected a union for Status, got %q", got.Types["Status"])
+	}
+	if got.Types["Sex"] != `"M" | "F"` {
+		t.Errorf("expected configured overrides to win, got %q", got.Types["Sex"])
+	}
+	if len(overrides.Types) != 1 {
+		t.Errorf("expected overrides not to be modified, got %v", overrides.Types)
+	}
+
+	var buf bytes.Buffer
+	field := parser.Field{Name: "Status", Type: "Status", BaseType: "Status", Kind: parser.KindString, Tag: `json:"status"`}
+	GenerateTypescriptInterfaces(&buf, map[string]parser.StructMeta{"User": {Name: "User", Fields: []parser.Field{field}}}, got)
+	if !strings.Contains(buf.String(), "\tstatus: Status;") {
+		t.Errorf("expected enum fields to use the union type\nGot:\n%s", buf.String())
+	}
+
+	// Enums with the same name in several packages get no union, unless overridden.
+	enums = append(enums,
+		parser.Enum{Name: "Status", Package: "example.com/billing", Kind: parser.KindInt, Values: []parser.EnumValue{{Name: "Paid", Value: "1"}}},
+		parser.Enum{Name: "Sex", Package: "example.com/billing", Kind: parser.KindString, Values: []parser.EnumValue{{Name: "Female", Value: `"Female"`}}},
+	)
+	got, diagnostics = WithEnums(overrides, enums)
+	if _, ok := got.Types["Status"]; ok {
+		t.Errorf("expected no union for the clashing Status enums, got %q", got.Types["Status"])
+	}
+	if got.Types["Sex"] != `"M" | "F"` {
+		t.Errorf("expected configured overrides to win, got %q", got.Types["Sex"])
+	}
+	if len(diagnostics) != 1 || diagnostics[0].Severity != parser.SeverityWarning || !strings.Contains(diagnostics[0].Message, "example.com/billing") {
+		t.Errorf("expected a warning for the Status clash, got %v", diagnostics)
+	}
+}